@@ -1,17 +1,22 @@
 package main
 
 import (
+	"context"
 	"github.com/go-redis/redis"
 	"github.com/sebasir/rate-limiter-example/config"
 	"github.com/sebasir/rate-limiter-example/http"
 	"github.com/sebasir/rate-limiter-example/manager"
+	"github.com/sebasir/rate-limiter-example/model"
 	pb "github.com/sebasir/rate-limiter-example/notification/proto"
 	ratelimiter "github.com/sebasir/rate-limiter-example/rate_limiter"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	_ "time/tzdata"
 )
 
 func init() {
@@ -28,18 +33,17 @@ func main() {
 		logger.Fatal("error retrieving env variables", zap.Error(err))
 	}
 
+	if !slices.Contains(model.DegradationPolicies, cfg.DegradationPolicy) {
+		logger.Fatal("invalid degradation policy", zap.String("policy", cfg.DegradationPolicy),
+			zap.Strings("policies", model.DegradationPolicies))
+	}
+
 	logger.Info("starting Rate Limiter app")
 
-	redisAddress := config.FormatAddress(cfg.RedisHost, cfg.RedisPort)
-	logger.Debug("redis server", zap.String("address", redisAddress))
-	rdb := redis.NewClient(&redis.Options{
-		Addr: redisAddress,
-	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	logger.Debug("connecting and flushing redis cache", zap.String("address", redisAddress))
-	if err := rdb.FlushDB().Err(); err != nil {
-		logger.Fatal("error connecting to Redis server", zap.Error(err), zap.String("address", redisAddress))
-	}
+	store, mgr := newBackend(ctx, cfg)
 
 	grpcServerAddress := config.FormatAddress(cfg.NotificationHost, cfg.NotificationGRPCPort)
 	logger.Debug("dialing to gRPC notification server", zap.String("address", grpcServerAddress))
@@ -60,12 +64,85 @@ func main() {
 		logger.Fatal("error setting GIN port env variable", zap.Error(err))
 	}
 
-	mgr := manager.NewClient(rdb)
-	delegate := ratelimiter.NewGRPCClient(c)
-	client := ratelimiter.NewClient(rdb, delegate, mgr)
+	logger.Debug("wrapping gRPC notification client with circuit breaker",
+		zap.Duration("timeout", cfg.NotificationTimeout), zap.Int("failure_threshold", cfg.BreakerFailureThreshold),
+		zap.Duration("open_timeout", cfg.BreakerOpenTimeout), zap.Int("half_open_requests", cfg.BreakerHalfOpenRequests))
+	delegate := ratelimiter.NewCircuitBreaker(ratelimiter.NewGRPCClient(c, cfg.NotificationTimeout),
+		cfg.BreakerFailureThreshold, cfg.BreakerOpenTimeout, cfg.BreakerHalfOpenRequests)
+	client := ratelimiter.NewClient(store, delegate, mgr, cfg.DegradationPolicy)
+
+	logger.Debug("starting deferred notifications worker", zap.Duration("interval", cfg.DeferredPollInterval))
+	go ratelimiter.NewDeferredWorker(store, client).Run(ctx, cfg.DeferredPollInterval)
+
 	controller := http.NewControllerWithConfig(client)
 	logger.Debug("starting GIN HTTP server", zap.Int("port", cfg.RateLimiterHttpPort))
 	if err = controller.StartServer(); err != nil {
 		logger.Fatal("error when serving HTTP", zap.Error(err), zap.Int("port", cfg.RateLimiterHttpPort))
 	}
 }
+
+// newBackend builds the store of the limits and the manager of the configs on the backend of the
+// app config.
+func newBackend(ctx context.Context, cfg *config.AppConfig) (ratelimiter.Store, manager.Service) {
+	logger := zap.L()
+	switch cfg.Backend {
+	case config.MemoryBackend:
+		logger.Info("using in-memory backend, limits and configs are not shared across instances")
+		return ratelimiter.NewMemoryStore(), manager.NewInMemoryClient()
+	case config.RedisBackend:
+		return newRedisBackend(ctx, cfg)
+	}
+
+	logger.Fatal("unknown backend", zap.String("backend", cfg.Backend))
+	return nil, nil
+}
+
+func newRedisBackend(ctx context.Context, cfg *config.AppConfig) (ratelimiter.Store, manager.Service) {
+	logger := zap.L()
+	rdb, redisAddress := newRedisClient(cfg)
+
+	logger.Debug("connecting to redis server", zap.String("address", redisAddress))
+	if err := rdb.Ping().Err(); err != nil {
+		logger.Fatal("error connecting to Redis server", zap.Error(err), zap.String("address", redisAddress))
+	}
+
+	logger.Debug("loading rate limiter scripts", zap.String("address", redisAddress))
+	if err := ratelimiter.LoadScripts(rdb); err != nil {
+		logger.Fatal("error loading rate limiter scripts", zap.Error(err), zap.String("address", redisAddress))
+	}
+
+	if cfg.ConfigCacheTTL <= 0 {
+		return ratelimiter.NewRedisStore(rdb), manager.NewClient(rdb)
+	}
+
+	logger.Debug("subscribing to notification config and recipient override invalidations", zap.Duration("ttl", cfg.ConfigCacheTTL))
+	mgr := manager.NewCachedClient(manager.NewClient(rdb), rdb, cfg.ConfigCacheTTL)
+	go mgr.Listen(ctx, rdb.Subscribe(model.ConfigInvalidationChannel, model.OverrideInvalidationChannel).Channel())
+	return ratelimiter.NewRedisStore(rdb), mgr
+}
+
+// newRedisClient builds a client of the Redis deployment of the app config: through Sentinel, a
+// Cluster, or a single server. It returns the addresses it connects to as well.
+func newRedisClient(cfg *config.AppConfig) (redis.UniversalClient, string) {
+	logger := zap.L()
+	switch {
+	case cfg.RedisSentinelMaster != "":
+		logger.Debug("redis sentinel", zap.String("master", cfg.RedisSentinelMaster),
+			zap.Strings("addresses", cfg.RedisSentinelAddresses))
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    cfg.RedisSentinelMaster,
+			SentinelAddrs: cfg.RedisSentinelAddresses,
+		}), strings.Join(cfg.RedisSentinelAddresses, ",")
+	case len(cfg.RedisClusterAddresses) > 0:
+		logger.Debug("redis cluster", zap.Strings("addresses", cfg.RedisClusterAddresses))
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs: cfg.RedisClusterAddresses,
+		}), strings.Join(cfg.RedisClusterAddresses, ",")
+	}
+
+	redisAddress := config.FormatAddress(cfg.RedisHost, cfg.RedisPort)
+	logger.Debug("redis server", zap.String("address", redisAddress))
+	return redis.NewClient(&redis.Options{
+		Addr: redisAddress,
+	}), redisAddress
+}
@@ -3,16 +3,42 @@ package config
 import (
 	"fmt"
 	"github.com/kelseyhightower/envconfig"
+	"time"
+)
+
+const (
+	RedisBackend  = "REDIS"
+	MemoryBackend = "MEMORY"
 )
 
 type AppConfig struct {
-	Debug                int    `envconfig:"DEBUG" default:"1"`
-	RateLimiterHttpPort  int    `envconfig:"RATE_LIMITER_HTTP_PORT" default:"8080"`
-	RedisHost            string `envconfig:"REDIS_HOST" default:"localhost"`
-	RedisPort            int    `envconfig:"REDIS_PORT" default:"6379"`
-	NotificationHost     string `envconfig:"NOTIFICATION_HOST" default:"localhost"`
-	NotificationHTTPPort int    `envconfig:"NOTIFICATION_HTTP_PORT" default:"8280"`
-	NotificationGRPCPort int    `envconfig:"NOTIFICATION_GRPC_PORT" default:"8281"`
+	Debug                int           `envconfig:"DEBUG" default:"1"`
+	RateLimiterHttpPort  int           `envconfig:"RATE_LIMITER_HTTP_PORT" default:"8080"`
+	RedisHost            string        `envconfig:"REDIS_HOST" default:"localhost"`
+	RedisPort            int           `envconfig:"REDIS_PORT" default:"6379"`
+	NotificationHost     string        `envconfig:"NOTIFICATION_HOST" default:"localhost"`
+	NotificationHTTPPort int           `envconfig:"NOTIFICATION_HTTP_PORT" default:"8280"`
+	NotificationGRPCPort int           `envconfig:"NOTIFICATION_GRPC_PORT" default:"8281"`
+	DeferredPollInterval time.Duration `envconfig:"DEFERRED_POLL_INTERVAL" default:"1s"`
+	ConfigCacheTTL       time.Duration `envconfig:"CONFIG_CACHE_TTL" default:"30s"`
+	NotificationTimeout  time.Duration `envconfig:"NOTIFICATION_TIMEOUT" default:"5s"`
+	// The circuit breaker of the notification service opens after BreakerFailureThreshold consecutive
+	// errors for BreakerOpenTimeout, and closes once BreakerHalfOpenRequests requests succeed then.
+	BreakerFailureThreshold int           `envconfig:"BREAKER_FAILURE_THRESHOLD" default:"5"`
+	BreakerOpenTimeout      time.Duration `envconfig:"BREAKER_OPEN_TIMEOUT" default:"30s"`
+	BreakerHalfOpenRequests int           `envconfig:"BREAKER_HALF_OPEN_REQUESTS" default:"1"`
+	// RedisSentinelMaster and RedisSentinelAddresses connect through Redis Sentinel to the named
+	// master, while RedisClusterAddresses seed a Redis Cluster client. Otherwise, RedisHost and
+	// RedisPort point to a single Redis server.
+	RedisSentinelMaster    string   `envconfig:"REDIS_SENTINEL_MASTER"`
+	RedisSentinelAddresses []string `envconfig:"REDIS_SENTINEL_ADDRESSES"`
+	RedisClusterAddresses  []string `envconfig:"REDIS_CLUSTER_ADDRESSES"`
+	// Backend is where the limits and configs are kept: REDIS, shared by every instance, or MEMORY,
+	// for single-instance deployments running without Redis.
+	Backend string `envconfig:"BACKEND" default:"REDIS"`
+	// DegradationPolicy applies to the notification types without one while Redis is unavailable:
+	// FAIL_OPEN, FAIL_CLOSED or FALLBACK.
+	DegradationPolicy string `envconfig:"DEGRADATION_POLICY" default:"FAIL_CLOSED"`
 }
 
 func (lc *AppConfig) Load() error {
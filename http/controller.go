@@ -1,12 +1,16 @@
 package http
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/sebasir/rate-limiter-example/manager"
 	"github.com/sebasir/rate-limiter-example/model"
 	pb "github.com/sebasir/rate-limiter-example/notification/proto"
 	"github.com/sebasir/rate-limiter-example/service"
 	"go.uber.org/zap"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type Controller interface {
@@ -14,6 +18,14 @@ type Controller interface {
 	SendNotification(ctx *gin.Context)
 	ListNotificationTypes(ctx *gin.Context)
 	SaveNotificationType(ctx *gin.Context)
+	ListShadowRejections(ctx *gin.Context)
+	ListRecipientOverrides(ctx *gin.Context)
+	SaveRecipientOverride(ctx *gin.Context)
+	DeleteRecipientOverride(ctx *gin.Context)
+	GetQuota(ctx *gin.Context)
+	ListQuotas(ctx *gin.Context)
+	ResetCounters(ctx *gin.Context)
+	GetStatus(ctx *gin.Context)
 }
 
 type controller struct {
@@ -47,6 +59,15 @@ func (c controller) StartServer() error {
 	if c.configClient != nil {
 		r.GET("/types", c.ListNotificationTypes)
 		r.PUT("/types", c.SaveNotificationType)
+		r.GET("/types/shadow-rejections", c.ListShadowRejections)
+		r.GET("/overrides/:recipient", c.ListRecipientOverrides)
+		r.PUT("/overrides", c.SaveRecipientOverride)
+		r.DELETE("/overrides/:recipient", c.DeleteRecipientOverride)
+		r.DELETE("/overrides/:recipient/:type", c.DeleteRecipientOverride)
+		r.GET("/quota/:recipient", c.ListQuotas)
+		r.GET("/quota/:recipient/:type", c.GetQuota)
+		r.DELETE("/counters", c.ResetCounters)
+		r.GET("/status", c.GetStatus)
 	}
 	return r.Run()
 }
@@ -75,6 +96,10 @@ func (c controller) SendNotification(ctx *gin.Context) {
 
 	c.logger.Debug("notification forwarded to service")
 	res, err := c.client.Send(notification)
+	if res != nil {
+		setRateLimitHeaders(ctx, res)
+	}
+
 	if err != nil {
 		c.logger.Error("error sending notification to client", zap.Error(err))
 		if res == nil {
@@ -98,6 +123,12 @@ func (c controller) SendNotification(ctx *gin.Context) {
 				"message": "notification was rejected by rate limiter, yet an error occurred",
 				"error":   err.Error(),
 			})
+		case pb.Status_QUEUED:
+			ctx.JSON(http.StatusAccepted, gin.H{
+				"message":     "notification was queued by rate limiter, yet an error occurred",
+				"scheduledAt": time.UnixMilli(res.ScheduledAtMs).UTC(),
+				"error":       err.Error(),
+			})
 		case pb.Status_INTERNAL_ERROR:
 			ctx.JSON(http.StatusInternalServerError, gin.H{
 				"message": "error occurred while processing request",
@@ -123,6 +154,27 @@ func (c controller) SendNotification(ctx *gin.Context) {
 		ctx.JSON(http.StatusTooManyRequests, gin.H{
 			"message": "notification was rejected by rate limiter",
 		})
+	case pb.Status_QUEUED:
+		ctx.JSON(http.StatusAccepted, gin.H{
+			"message":     "notification was queued by rate limiter",
+			"scheduledAt": time.UnixMilli(res.ScheduledAtMs).UTC(),
+		})
+	case pb.Status_QUIET_HOURS:
+		if res.ScheduledAtMs > 0 {
+			ctx.JSON(http.StatusAccepted, gin.H{
+				"message":     "notification was queued until quiet hours end",
+				"scheduledAt": time.UnixMilli(res.ScheduledAtMs).UTC(),
+			})
+			return
+		}
+
+		ctx.JSON(http.StatusTooManyRequests, gin.H{
+			"message": "notification was rejected during quiet hours",
+		})
+	case pb.Status_DEDUPLICATED:
+		ctx.JSON(http.StatusOK, gin.H{
+			"message": "notification was deduplicated, an identical one was already sent to recipient",
+		})
 	case pb.Status_INTERNAL_ERROR:
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": "internal server error",
@@ -134,6 +186,30 @@ func (c controller) SendNotification(ctx *gin.Context) {
 	}
 }
 
+// setRateLimitHeaders emits the rate limit state of the result as the RateLimit header fields of
+// the IETF draft, adding Retry-After to rejections. Rejections during quiet hours only get the
+// Retry-After, as they are not bound to any limit.
+func setRateLimitHeaders(ctx *gin.Context, res *pb.Result) {
+	if res.Status == pb.Status_QUIET_HOURS && res.ScheduledAtMs == 0 {
+		ctx.Header("Retry-After", toSeconds(res.RetryAfterMs))
+	}
+
+	if res.Limit == 0 {
+		return
+	}
+
+	ctx.Header("RateLimit-Limit", strconv.FormatInt(res.Limit, 10))
+	ctx.Header("RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+	ctx.Header("RateLimit-Reset", toSeconds(res.ResetAfterMs))
+	if res.Status == pb.Status_REJECTED {
+		ctx.Header("Retry-After", toSeconds(res.RetryAfterMs))
+	}
+}
+
+func toSeconds(ms int64) string {
+	return strconv.FormatInt((ms+999)/1000, 10)
+}
+
 func (c controller) ListNotificationTypes(ctx *gin.Context) {
 	configs, err := c.configClient.ListNotificationConfig()
 	if err != nil {
@@ -185,3 +261,166 @@ func (c controller) SaveNotificationType(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusNoContent, nil)
 }
+
+func (c controller) ListShadowRejections(ctx *gin.Context) {
+	counts, err := c.configClient.ListShadowRejections()
+	if err != nil {
+		c.logger.Error("error listing shadow rejections", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": "internal server error",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, counts)
+}
+
+func (c controller) ListRecipientOverrides(ctx *gin.Context) {
+	overrides, err := c.configClient.ListRecipientOverrides(ctx.Param("recipient"))
+	if err != nil {
+		c.logger.Error("error listing recipient overrides", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": "internal server error",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if len(overrides) == 0 {
+		c.logger.Info("no recipient overrides found")
+		ctx.JSON(http.StatusNoContent, nil)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, overrides)
+}
+
+func (c controller) SaveRecipientOverride(ctx *gin.Context) {
+	override, err := ParseRequestBody[model.Override](ctx.Request.Body)
+	if err != nil {
+		c.logger.Error("error parsing request body", zap.Error(err))
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"message": "error processing input",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if err := c.validator.Struct(override); err != nil {
+		c.logger.Error("error parsing request body", zap.Error(err))
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"message": "error processing input",
+			"error":   c.validator.Translate(err),
+		})
+		return
+	}
+
+	if err := c.configClient.PersistRecipientOverride(override); err != nil {
+		c.logger.Error("error persisting recipient override", zap.Error(err))
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"message": "error persisting recipient override",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
+
+func (c controller) DeleteRecipientOverride(ctx *gin.Context) {
+	err := c.configClient.DeleteRecipientOverride(ctx.Param("recipient"), ctx.Param("type"))
+	if errors.Is(err, manager.ErrRecipientOverrideNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"message": "recipient override not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.logger.Error("error deleting recipient override", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": "internal server error",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusNoContent, nil)
+}
+
+func (c controller) GetQuota(ctx *gin.Context) {
+	quota, err := c.configClient.GetQuota(ctx.Param("recipient"), ctx.Param("type"))
+	if errors.Is(err, manager.ErrNotificationConfigNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"message": "notification type not found",
+		})
+		return
+	}
+
+	if err != nil {
+		c.logger.Error("error retrieving quota", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": "internal server error",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, quota)
+}
+
+func (c controller) ListQuotas(ctx *gin.Context) {
+	quotas, err := c.configClient.ListQuotas(ctx.Param("recipient"))
+	if err != nil {
+		c.logger.Error("error listing quotas", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": "internal server error",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if len(quotas) == 0 {
+		c.logger.Info("no quotas found")
+		ctx.JSON(http.StatusNoContent, nil)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, quotas)
+}
+
+// ResetCounters deletes the counters matching the recipient and type query parameters, requiring
+// at least one of them. The X-Requested-By header (or the client IP) identifies the actor.
+func (c controller) ResetCounters(ctx *gin.Context) {
+	recipient, notificationType := ctx.Query("recipient"), ctx.Query("type")
+	if recipient == "" && notificationType == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"message": "recipient or type query parameter is required",
+		})
+		return
+	}
+
+	actor := ctx.GetHeader("X-Requested-By")
+	if actor == "" {
+		actor = ctx.ClientIP()
+	}
+
+	deleted, err := c.configClient.ResetCounters(recipient, notificationType, actor)
+	if err != nil {
+		c.logger.Error("error resetting counters", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": "internal server error",
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"deleted": deleted,
+	})
+}
+
+func (c controller) GetStatus(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.configClient.Status())
+}
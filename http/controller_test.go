@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/assert/v2"
 	. "github.com/ovechkin-dm/mockio/mock"
+	"github.com/sebasir/rate-limiter-example/manager"
 	"github.com/sebasir/rate-limiter-example/model"
 	"github.com/sebasir/rate-limiter-example/notification/proto"
 	ratelimiter "github.com/sebasir/rate-limiter-example/rate_limiter"
@@ -17,6 +18,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -62,6 +64,7 @@ type testCase struct {
 	wantedStatus  int
 	wantedMessage string
 	wantedList    []*model.Config
+	wantedHeaders map[string]string
 }
 
 type fields struct {
@@ -106,6 +109,37 @@ func (c *extendedClientMock) buildMock() service.ExtendedClient {
 	return extClientMock
 }
 
+type overrideClientMock struct {
+	listOverridesVal       []*model.Override
+	listOverridesErr       error
+	listOverridesExclude   bool
+	persistOverrideErr     error
+	persistOverrideExclude bool
+	deleteOverrideErr      error
+	deleteOverrideExclude  bool
+}
+
+func (c *overrideClientMock) buildMock() service.ExtendedClient {
+	extClientMock := Mock[service.ExtendedClient]()
+
+	if !c.listOverridesExclude {
+		When(extClientMock.ListRecipientOverrides(AnyString())).
+			ThenReturn(c.listOverridesVal, c.listOverridesErr)
+	}
+
+	if !c.persistOverrideExclude {
+		When(extClientMock.PersistRecipientOverride(Any[*model.Override]())).
+			ThenReturn(c.persistOverrideErr)
+	}
+
+	if !c.deleteOverrideExclude {
+		When(extClientMock.DeleteRecipientOverride(AnyString(), AnyString())).
+			ThenReturn(c.deleteOverrideErr)
+	}
+
+	return extClientMock
+}
+
 type clientMock struct {
 	sendErr     error
 	sendVal     *proto.Result
@@ -225,6 +259,37 @@ func Test_controller_SaveNotificationType(t *testing.T) {
 				input: configStrMap["News"],
 			},
 			wantedStatus: http.StatusNoContent,
+		}, {
+			name: "OK_ISO_Window_Config_Saved",
+			fields: fields{
+				configClient: (&extendedClientMock{
+					ListConfigsExclude: true,
+				}).buildMock(),
+				input: `{"name":"News","limitCount":4,"window":"P1M","alignment":"CALENDAR","limits":[{"limitCount":2,"window":"P1W"},{"limitCount":1,"timeAmount":1,"timeUnit":"DAY"}]}`,
+			},
+			wantedStatus: http.StatusNoContent,
+		}, {
+			name: "VALIDATION_Window_With_Time_Unit",
+			fields: fields{
+				configClient: (&extendedClientMock{
+					persistConfigExclude: true,
+					ListConfigsExclude:   true,
+				}).buildMock(),
+				input: `{"name":"News","limitCount":1,"timeUnit":"DAY","window":"P1D"}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Config.Window":"Window must be an ISO-8601 duration of whole seconds up to months (e.g. PT90M, P1D or P1M), replacing timeAmount and timeUnit"},"message":"error processing input"}`,
+		}, {
+			name: "VALIDATION_Invalid_Limit_Window",
+			fields: fields{
+				configClient: (&extendedClientMock{
+					persistConfigExclude: true,
+					ListConfigsExclude:   true,
+				}).buildMock(),
+				input: `{"name":"News","limitCount":1,"window":"P1D","limits":[{"limitCount":2,"window":"P1M15D"}]}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Config.Limits[0].Window":"Window must be an ISO-8601 duration of whole seconds up to months (e.g. PT90M, P1D or P1M), replacing timeAmount and timeUnit"},"message":"error processing input"}`,
 		}, {
 			name: "VALIDATION_Invalid_Input",
 			fields: fields{
@@ -235,7 +300,7 @@ func Test_controller_SaveNotificationType(t *testing.T) {
 				input: `{"hello":"world"}`,
 			},
 			wantedStatus:  http.StatusBadRequest,
-			wantedMessage: `{"error":{"Config.LimitCount":"LimitCount must be 1 or greater","Config.Name":"Name is a required field","Config.TimeAmount":"TimeAmount must be 1 or greater","Config.TimeUnit":"TimeUnit must be one of SECOND, MINUTE, HOUR, DAY"},"message":"error processing input"}`,
+			wantedMessage: `{"error":{"Config.LimitCount":"LimitCount must be 1 or greater","Config.Name":"Name is a required field","Config.TimeAmount":"TimeAmount must be 1 or greater","Config.TimeUnit":"TimeUnit must be one of SECOND, MINUTE, HOUR, DAY, WEEK, MONTH"},"message":"error processing input"}`,
 		}, {
 			name: "VALIDATION_Missing_Required_Field_Input",
 			fields: fields{
@@ -247,6 +312,116 @@ func Test_controller_SaveNotificationType(t *testing.T) {
 			},
 			wantedStatus:  http.StatusBadRequest,
 			wantedMessage: `{"error":{"Config.Name":"Name is a required field"},"message":"error processing input"}`,
+		}, {
+			name: "VALIDATION_Token_Bucket_Missing_Fields",
+			fields: fields{
+				configClient: (&extendedClientMock{
+					persistConfigExclude: true,
+					ListConfigsExclude:   true,
+				}).buildMock(),
+				input: `{"name":"Marketing","limitCount":1,"timeUnit":"MINUTE","timeAmount":10,"algorithm":"TOKEN_BUCKET"}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Config.BurstCapacity":"BurstCapacity is a required field","Config.RefillRate":"RefillRate is a required field"},"message":"error processing input"}`,
+		}, {
+			name: "VALIDATION_Unknown_Algorithm",
+			fields: fields{
+				configClient: (&extendedClientMock{
+					persistConfigExclude: true,
+					ListConfigsExclude:   true,
+				}).buildMock(),
+				input: `{"name":"Marketing","limitCount":1,"timeUnit":"MINUTE","timeAmount":10,"algorithm":"LEAKY_BUCKET"}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Config.Algorithm":"Algorithm must be one of FIXED_WINDOW, SLIDING_WINDOW_LOG, TOKEN_BUCKET, GCRA"},"message":"error processing input"}`,
+		}, {
+			name: "VALIDATION_Unknown_Counting_Policy",
+			fields: fields{
+				configClient: (&extendedClientMock{
+					persistConfigExclude: true,
+					ListConfigsExclude:   true,
+				}).buildMock(),
+				input: `{"name":"Marketing","limitCount":1,"timeUnit":"MINUTE","timeAmount":10,"countingPolicy":"REJECTED"}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Config.CountingPolicy":"CountingPolicy must be one of ALL, ACCEPTED"},"message":"error processing input"}`,
+		}, {
+			name: "VALIDATION_Unknown_Mode",
+			fields: fields{
+				configClient: (&extendedClientMock{
+					persistConfigExclude: true,
+					ListConfigsExclude:   true,
+				}).buildMock(),
+				input: `{"name":"Marketing","limitCount":1,"timeUnit":"MINUTE","timeAmount":10,"mode":"DRY_RUN"}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Config.Mode":"Mode must be one of ENFORCE, SHADOW, DISABLED"},"message":"error processing input"}`,
+		}, {
+			name: "VALIDATION_Unknown_Overflow_Policy",
+			fields: fields{
+				configClient: (&extendedClientMock{
+					persistConfigExclude: true,
+					ListConfigsExclude:   true,
+				}).buildMock(),
+				input: `{"name":"Marketing","limitCount":1,"timeUnit":"MINUTE","timeAmount":10,"overflowPolicy":"DROP"}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Config.OverflowPolicy":"OverflowPolicy must be one of REJECT, DEFER"},"message":"error processing input"}`,
+		}, {
+			name: "VALIDATION_Unknown_Degradation_Policy",
+			fields: fields{
+				configClient: (&extendedClientMock{
+					persistConfigExclude: true,
+					ListConfigsExclude:   true,
+				}).buildMock(),
+				input: `{"name":"Marketing","limitCount":1,"timeUnit":"MINUTE","timeAmount":10,"degradationPolicy":"IGNORE"}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Config.DegradationPolicy":"DegradationPolicy must be one of FAIL_OPEN, FAIL_CLOSED, FALLBACK"},"message":"error processing input"}`,
+		}, {
+			name: "VALIDATION_Unknown_Alignment",
+			fields: fields{
+				configClient: (&extendedClientMock{
+					persistConfigExclude: true,
+					ListConfigsExclude:   true,
+				}).buildMock(),
+				input: `{"name":"News","limitCount":1,"timeUnit":"DAY","timeAmount":1,"alignment":"MIDNIGHT"}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Config.Alignment":"Alignment must be one of ROLLING, CALENDAR"},"message":"error processing input"}`,
+		}, {
+			name: "VALIDATION_Unknown_Time_Zone",
+			fields: fields{
+				configClient: (&extendedClientMock{
+					persistConfigExclude: true,
+					ListConfigsExclude:   true,
+				}).buildMock(),
+				input: `{"name":"News","limitCount":1,"timeUnit":"DAY","timeAmount":1,"alignment":"CALENDAR","timeZone":"Mars/Olympus_Mons"}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Config.TimeZone":"TimeZone must be an IANA time zone"},"message":"error processing input"}`,
+		}, {
+			name: "VALIDATION_Invalid_Quiet_Hours",
+			fields: fields{
+				configClient: (&extendedClientMock{
+					persistConfigExclude: true,
+					ListConfigsExclude:   true,
+				}).buildMock(),
+				input: `{"name":"Marketing","limitCount":1,"timeUnit":"DAY","timeAmount":1,"quietHours":[{"days":["MONDAY","FUNDAY"],"start":"22:00","end":"7am"}]}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Config.QuietHours[0].Days[1]":"Days[1] must be one of SUNDAY, MONDAY, TUESDAY, WEDNESDAY, THURSDAY, FRIDAY, SATURDAY","Config.QuietHours[0].End":"End must be a time of the day as HH:MM"},"message":"error processing input"}`,
+		}, {
+			name: "VALIDATION_Invalid_Dedup_Window",
+			fields: fields{
+				configClient: (&extendedClientMock{
+					persistConfigExclude: true,
+					ListConfigsExclude:   true,
+				}).buildMock(),
+				input: `{"name":"Marketing","limitCount":1,"timeUnit":"DAY","timeAmount":1,"dedupWindow":"10m"}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Config.DedupWindow":"DedupWindow must be an ISO-8601 duration of whole seconds up to months (e.g. PT10M, P1D or P1M)"},"message":"error processing input"}`,
 		}, {
 			name: "ERROR_Error_Persisting",
 			fields: fields{
@@ -327,6 +502,117 @@ func Test_controller_SendNotification(t *testing.T) {
 			},
 			wantedStatus:  http.StatusTooManyRequests,
 			wantedMessage: `{"message":"notification was rejected by rate limiter"}`,
+		}, {
+			name: "OK_Notification_Queued",
+			fields: fields{
+				client: (&clientMock{
+					sendVal: &proto.Result{
+						Status:          proto.Status_QUEUED,
+						ResponseMessage: "notification to recipient was queued",
+						ScheduledAtMs:   1700000030000,
+					},
+				}).buildMock(),
+				input: okNotification,
+			},
+			wantedStatus:  http.StatusAccepted,
+			wantedMessage: `{"message":"notification was queued by rate limiter","scheduledAt":"2023-11-14T22:13:50Z"}`,
+		}, {
+			name: "OK_Notification_Held_By_Quiet_Hours",
+			fields: fields{
+				client: (&clientMock{
+					sendVal: &proto.Result{
+						Status:          proto.Status_QUIET_HOURS,
+						ResponseMessage: "notification to recipient was rejected, quiet hours end at 2023-11-15T07:00:00Z",
+						RetryAfterMs:    3600000,
+					},
+				}).buildMock(),
+				input: okNotification,
+			},
+			wantedStatus:  http.StatusTooManyRequests,
+			wantedMessage: `{"message":"notification was rejected during quiet hours"}`,
+			wantedHeaders: map[string]string{
+				"RateLimit-Limit": "",
+				"Retry-After":     "3600",
+			},
+		}, {
+			name: "OK_Notification_Queued_By_Quiet_Hours",
+			fields: fields{
+				client: (&clientMock{
+					sendVal: &proto.Result{
+						Status:          proto.Status_QUIET_HOURS,
+						ResponseMessage: "notification to recipient was queued, quiet hours end at 2023-11-14T22:13:50Z",
+						RetryAfterMs:    30000,
+						ScheduledAtMs:   1700000030000,
+					},
+				}).buildMock(),
+				input: okNotification,
+			},
+			wantedStatus:  http.StatusAccepted,
+			wantedMessage: `{"message":"notification was queued until quiet hours end","scheduledAt":"2023-11-14T22:13:50Z"}`,
+			wantedHeaders: map[string]string{
+				"Retry-After": "",
+			},
+		}, {
+			name: "OK_Notification_Deduplicated",
+			fields: fields{
+				client: (&clientMock{
+					sendVal: &proto.Result{
+						Status:          proto.Status_DEDUPLICATED,
+						ResponseMessage: "notification to recipient was deduplicated, an identical one was sent within PT10M",
+					},
+				}).buildMock(),
+				input: okNotification,
+			},
+			wantedStatus:  http.StatusOK,
+			wantedMessage: `{"message":"notification was deduplicated, an identical one was already sent to recipient"}`,
+			wantedHeaders: map[string]string{
+				"RateLimit-Limit": "",
+				"Retry-After":     "",
+			},
+		}, {
+			name: "OK_Notification_Sent_Rate_Limit_Headers",
+			fields: fields{
+				client: (&clientMock{
+					sendVal: &proto.Result{
+						Status:          proto.Status_SENT,
+						ResponseMessage: "notification sent to recipient",
+						Limit:           3,
+						Remaining:       2,
+						ResetAfterMs:    59001,
+					},
+				}).buildMock(),
+				input: okNotification,
+			},
+			wantedStatus:  http.StatusOK,
+			wantedMessage: `{"message":"notification sent to recipient"}`,
+			wantedHeaders: map[string]string{
+				"RateLimit-Limit":     "3",
+				"RateLimit-Remaining": "2",
+				"RateLimit-Reset":     "60",
+				"Retry-After":         "",
+			},
+		}, {
+			name: "OK_Notification_Rejected_Rate_Limit_Headers",
+			fields: fields{
+				client: (&clientMock{
+					sendVal: &proto.Result{
+						Status:          proto.Status_REJECTED,
+						ResponseMessage: "notification to recipient was rejected",
+						Limit:           3,
+						ResetAfterMs:    60000,
+						RetryAfterMs:    1500,
+					},
+				}).buildMock(),
+				input: okNotification,
+			},
+			wantedStatus:  http.StatusTooManyRequests,
+			wantedMessage: `{"message":"notification was rejected by rate limiter"}`,
+			wantedHeaders: map[string]string{
+				"RateLimit-Limit":     "3",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "60",
+				"Retry-After":         "2",
+			},
 		}, {
 			name: "NO_ERROR_Invalid_Input",
 			fields: fields{
@@ -450,6 +736,481 @@ func Test_controller_SendNotification(t *testing.T) {
 
 			assert.Equal(t, tt.wantedStatus, w.Code)
 			assert.Equal(t, tt.wantedMessage, w.Body.String())
+			for header, value := range tt.wantedHeaders {
+				assert.Equal(t, value, w.Header().Get(header))
+			}
+		})
+	}
+}
+
+func Test_controller_ListShadowRejections(t *testing.T) {
+	SetUp(t)
+
+	tests := []struct {
+		name          string
+		counts        map[string]int64
+		err           error
+		wantedStatus  int
+		wantedMessage string
+	}{
+		{
+			name:          "OK_Shadow_Rejections_Listed",
+			counts:        map[string]int64{"News": 3},
+			wantedStatus:  http.StatusOK,
+			wantedMessage: `{"News":3}`,
+		}, {
+			name:          "ERROR_Listing_Shadow_Rejections_From_Backend",
+			err:           backendErr,
+			wantedStatus:  http.StatusInternalServerError,
+			wantedMessage: `{"error":"some backend error","message":"internal server error"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extClientMock := Mock[service.ExtendedClient]()
+			When(extClientMock.ListShadowRejections()).ThenReturn(tt.counts, tt.err)
+
+			c := controller{
+				configClient: extClientMock,
+				logger:       zap.L(),
+				validator:    val,
+			}
+
+			w := httptest.NewRecorder()
+			ctx := getTestGinContext(w)
+			ctx.Request.Method = "GET"
+			c.ListShadowRejections(ctx)
+
+			assert.Equal(t, tt.wantedStatus, w.Code)
+			assert.Equal(t, tt.wantedMessage, w.Body.String())
+		})
+	}
+}
+
+func Test_controller_ListRecipientOverrides(t *testing.T) {
+	SetUp(t)
+
+	tests := []testCase{
+		{
+			name: "OK_Recipient_Overrides_Listed",
+			fields: fields{
+				configClient: (&overrideClientMock{
+					listOverridesVal: []*model.Override{{
+						Recipient: "smotavitam@gmail.com",
+						Exempt:    true,
+					}},
+					persistOverrideExclude: true,
+					deleteOverrideExclude:  true,
+				}).buildMock(),
+			},
+			wantedStatus:  http.StatusOK,
+			wantedMessage: `[{"recipient":"smotavitam@gmail.com","exempt":true}]`,
+		}, {
+			name: "ERROR_No_Recipient_Overrides_Found",
+			fields: fields{
+				configClient: (&overrideClientMock{
+					listOverridesVal:       []*model.Override{},
+					persistOverrideExclude: true,
+					deleteOverrideExclude:  true,
+				}).buildMock(),
+			},
+			wantedStatus: http.StatusNoContent,
+		}, {
+			name: "ERROR_Listing_Overrides_From_Backend",
+			fields: fields{
+				configClient: (&overrideClientMock{
+					listOverridesErr:       backendErr,
+					persistOverrideExclude: true,
+					deleteOverrideExclude:  true,
+				}).buildMock(),
+			},
+			wantedStatus:  http.StatusInternalServerError,
+			wantedMessage: `{"error":"some backend error","message":"internal server error"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := controller{
+				configClient: tt.fields.configClient,
+				logger:       zap.L(),
+				validator:    val,
+			}
+
+			w := httptest.NewRecorder()
+			ctx := getTestGinContext(w)
+			ctx.Request.Method = "GET"
+			ctx.Params = gin.Params{{Key: "recipient", Value: "smotavitam@gmail.com"}}
+			c.ListRecipientOverrides(ctx)
+
+			assert.Equal(t, tt.wantedStatus, w.Code)
+			assert.Equal(t, tt.wantedMessage, w.Body.String())
+		})
+	}
+}
+
+func Test_controller_SaveRecipientOverride(t *testing.T) {
+	SetUp(t)
+	mockPut := func(c *gin.Context, content string) {
+		c.Request.Method = "PUT"
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Request.Body = io.NopCloser(bytes.NewBuffer([]byte(content)))
+	}
+
+	tests := []testCase{
+		{
+			name: "OK_Recipient_Override_Saved",
+			fields: fields{
+				configClient: (&overrideClientMock{
+					listOverridesExclude:  true,
+					deleteOverrideExclude: true,
+				}).buildMock(),
+				input: `{"recipient":"smotavitam@gmail.com","notificationType":"News","limitCount":5,"timeUnit":"DAY","timeAmount":1}`,
+			},
+			wantedStatus: http.StatusNoContent,
+		}, {
+			name: "OK_Recipient_Exemption_Saved",
+			fields: fields{
+				configClient: (&overrideClientMock{
+					listOverridesExclude:  true,
+					deleteOverrideExclude: true,
+				}).buildMock(),
+				input: `{"recipient":"smotavitam@gmail.com","exempt":true}`,
+			},
+			wantedStatus: http.StatusNoContent,
+		}, {
+			name: "OK_Recipient_Override_With_Window_Saved",
+			fields: fields{
+				configClient: (&overrideClientMock{
+					listOverridesExclude:  true,
+					deleteOverrideExclude: true,
+				}).buildMock(),
+				input: `{"recipient":"smotavitam@gmail.com","notificationType":"News","limitCount":5,"window":"PT36H"}`,
+			},
+			wantedStatus: http.StatusNoContent,
+		}, {
+			name: "VALIDATION_Window_With_Time_Unit",
+			fields: fields{
+				configClient: (&overrideClientMock{
+					listOverridesExclude:   true,
+					persistOverrideExclude: true,
+					deleteOverrideExclude:  true,
+				}).buildMock(),
+				input: `{"recipient":"smotavitam@gmail.com","limitCount":5,"timeUnit":"DAY","window":"P1D"}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Override.Window":"Window must be an ISO-8601 duration of whole seconds up to months (e.g. PT90M, P1D or P1M), replacing timeAmount and timeUnit"},"message":"error processing input"}`,
+		}, {
+			name: "VALIDATION_Missing_Limits",
+			fields: fields{
+				configClient: (&overrideClientMock{
+					listOverridesExclude:   true,
+					persistOverrideExclude: true,
+					deleteOverrideExclude:  true,
+				}).buildMock(),
+				input: `{"recipient":"smotavitam"}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":{"Override.LimitCount":"LimitCount is a required field","Override.Recipient":"Recipient must be a valid email address","Override.TimeAmount":"TimeAmount is a required field","Override.TimeUnit":"TimeUnit is a required field"},"message":"error processing input"}`,
+		}, {
+			name: "ERROR_Error_Persisting",
+			fields: fields{
+				configClient: (&overrideClientMock{
+					listOverridesExclude:  true,
+					persistOverrideErr:    backendErr,
+					deleteOverrideExclude: true,
+				}).buildMock(),
+				input: `{"recipient":"smotavitam@gmail.com","exempt":true}`,
+			},
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"error":"some backend error","message":"error persisting recipient override"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := controller{
+				configClient: tt.fields.configClient,
+				logger:       zap.L(),
+				validator:    val,
+			}
+
+			w := httptest.NewRecorder()
+			ctx := getTestGinContext(w)
+			mockPut(ctx, tt.fields.input)
+			c.SaveRecipientOverride(ctx)
+
+			assert.Equal(t, tt.wantedStatus, w.Code)
+			assert.Equal(t, tt.wantedMessage, w.Body.String())
+		})
+	}
+}
+
+func Test_controller_DeleteRecipientOverride(t *testing.T) {
+	SetUp(t)
+
+	tests := []testCase{
+		{
+			name: "OK_Recipient_Override_Deleted",
+			fields: fields{
+				configClient: (&overrideClientMock{
+					listOverridesExclude:   true,
+					persistOverrideExclude: true,
+				}).buildMock(),
+			},
+			wantedStatus: http.StatusNoContent,
+		}, {
+			name: "ERROR_Recipient_Override_Not_Found",
+			fields: fields{
+				configClient: (&overrideClientMock{
+					listOverridesExclude:   true,
+					persistOverrideExclude: true,
+					deleteOverrideErr:      manager.ErrRecipientOverrideNotFound,
+				}).buildMock(),
+			},
+			wantedStatus:  http.StatusNotFound,
+			wantedMessage: `{"message":"recipient override not found"}`,
+		}, {
+			name: "ERROR_Deleting_Override_From_Backend",
+			fields: fields{
+				configClient: (&overrideClientMock{
+					listOverridesExclude:   true,
+					persistOverrideExclude: true,
+					deleteOverrideErr:      backendErr,
+				}).buildMock(),
+			},
+			wantedStatus:  http.StatusInternalServerError,
+			wantedMessage: `{"error":"some backend error","message":"internal server error"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := controller{
+				configClient: tt.fields.configClient,
+				logger:       zap.L(),
+				validator:    val,
+			}
+
+			w := httptest.NewRecorder()
+			ctx := getTestGinContext(w)
+			ctx.Request.Method = "DELETE"
+			ctx.Params = gin.Params{{Key: "recipient", Value: "smotavitam@gmail.com"}, {Key: "type", Value: "News"}}
+			c.DeleteRecipientOverride(ctx)
+
+			assert.Equal(t, tt.wantedStatus, w.Code)
+			assert.Equal(t, tt.wantedMessage, w.Body.String())
+		})
+	}
+}
+
+func Test_controller_GetQuota(t *testing.T) {
+	SetUp(t)
+
+	tests := []struct {
+		name          string
+		quota         *model.Quota
+		err           error
+		wantedStatus  int
+		wantedMessage string
+	}{
+		{
+			name: "OK_Quota_Retrieved",
+			quota: &model.Quota{
+				Recipient:        "smotavitam@gmail.com",
+				NotificationType: "News",
+				Limit:            1,
+				Count:            1,
+			},
+			wantedStatus:  http.StatusOK,
+			wantedMessage: `{"recipient":"smotavitam@gmail.com","notificationType":"News","limit":1,"count":1,"remaining":0}`,
+		}, {
+			name:          "ERROR_Notification_Type_Not_Found",
+			err:           errors.Join(manager.ErrNotificationConfigNotFound, backendErr),
+			wantedStatus:  http.StatusNotFound,
+			wantedMessage: `{"message":"notification type not found"}`,
+		}, {
+			name:          "ERROR_Retrieving_Quota_From_Backend",
+			err:           backendErr,
+			wantedStatus:  http.StatusInternalServerError,
+			wantedMessage: `{"error":"some backend error","message":"internal server error"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extClientMock := Mock[service.ExtendedClient]()
+			When(extClientMock.GetQuota("smotavitam@gmail.com", "News")).ThenReturn(tt.quota, tt.err)
+
+			c := controller{
+				configClient: extClientMock,
+				logger:       zap.L(),
+				validator:    val,
+			}
+
+			w := httptest.NewRecorder()
+			ctx := getTestGinContext(w)
+			ctx.Request.Method = "GET"
+			ctx.Params = gin.Params{{Key: "recipient", Value: "smotavitam@gmail.com"}, {Key: "type", Value: "News"}}
+			c.GetQuota(ctx)
+
+			assert.Equal(t, tt.wantedStatus, w.Code)
+			assert.Equal(t, tt.wantedMessage, w.Body.String())
+		})
+	}
+}
+
+func Test_controller_ListQuotas(t *testing.T) {
+	SetUp(t)
+
+	tests := []struct {
+		name          string
+		quotas        []*model.Quota
+		err           error
+		wantedStatus  int
+		wantedMessage string
+	}{
+		{
+			name: "OK_Quotas_Listed",
+			quotas: []*model.Quota{{
+				Recipient:        "smotavitam@gmail.com",
+				NotificationType: "News",
+				Unlimited:        true,
+			}},
+			wantedStatus:  http.StatusOK,
+			wantedMessage: `[{"recipient":"smotavitam@gmail.com","notificationType":"News","unlimited":true,"limit":0,"count":0,"remaining":0}]`,
+		}, {
+			name:         "ERROR_No_Quotas_Found",
+			quotas:       []*model.Quota{},
+			wantedStatus: http.StatusNoContent,
+		}, {
+			name:          "ERROR_Listing_Quotas_From_Backend",
+			err:           backendErr,
+			wantedStatus:  http.StatusInternalServerError,
+			wantedMessage: `{"error":"some backend error","message":"internal server error"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extClientMock := Mock[service.ExtendedClient]()
+			When(extClientMock.ListQuotas("smotavitam@gmail.com")).ThenReturn(tt.quotas, tt.err)
+
+			c := controller{
+				configClient: extClientMock,
+				logger:       zap.L(),
+				validator:    val,
+			}
+
+			w := httptest.NewRecorder()
+			ctx := getTestGinContext(w)
+			ctx.Request.Method = "GET"
+			ctx.Params = gin.Params{{Key: "recipient", Value: "smotavitam@gmail.com"}}
+			c.ListQuotas(ctx)
+
+			assert.Equal(t, tt.wantedStatus, w.Code)
+			assert.Equal(t, tt.wantedMessage, w.Body.String())
+		})
+	}
+}
+
+func Test_controller_ResetCounters(t *testing.T) {
+	SetUp(t)
+
+	tests := []struct {
+		name          string
+		query         string
+		deleted       int64
+		err           error
+		resetExclude  bool
+		wantedStatus  int
+		wantedMessage string
+	}{
+		{
+			name:          "OK_Counters_Reset",
+			query:         "recipient=smotavitam@gmail.com&type=News",
+			deleted:       2,
+			wantedStatus:  http.StatusOK,
+			wantedMessage: `{"deleted":2}`,
+		}, {
+			name:          "ERROR_Missing_Filters",
+			resetExclude:  true,
+			wantedStatus:  http.StatusBadRequest,
+			wantedMessage: `{"message":"recipient or type query parameter is required"}`,
+		}, {
+			name:          "ERROR_Resetting_Counters_On_Backend",
+			query:         "recipient=smotavitam@gmail.com&type=News",
+			err:           backendErr,
+			wantedStatus:  http.StatusInternalServerError,
+			wantedMessage: `{"error":"some backend error","message":"internal server error"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extClientMock := Mock[service.ExtendedClient]()
+			if !tt.resetExclude {
+				When(extClientMock.ResetCounters("smotavitam@gmail.com", "News", "admin")).
+					ThenReturn(tt.deleted, tt.err)
+			}
+
+			c := controller{
+				configClient: extClientMock,
+				logger:       zap.L(),
+				validator:    val,
+			}
+
+			w := httptest.NewRecorder()
+			ctx := getTestGinContext(w)
+			ctx.Request.Method = "DELETE"
+			ctx.Request.URL.RawQuery = tt.query
+			ctx.Request.Header.Set("X-Requested-By", "admin")
+			c.ResetCounters(ctx)
+
+			assert.Equal(t, tt.wantedStatus, w.Code)
+			assert.Equal(t, tt.wantedMessage, w.Body.String())
+		})
+	}
+}
+
+func Test_controller_GetStatus(t *testing.T) {
+	SetUp(t)
+
+	openedAt := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		status        *model.Status
+		wantedMessage string
+	}{
+		{
+			name:          "OK_Store_Up_Without_Breaker",
+			status:        &model.Status{Store: model.StatusUp},
+			wantedMessage: `{"store":"UP"}`,
+		}, {
+			name: "OK_Breaker_Open",
+			status: &model.Status{
+				Store: model.StatusDown,
+				Delegate: &model.BreakerStatus{
+					State:               model.BreakerOpen,
+					ConsecutiveFailures: 5,
+					OpenedAt:            &openedAt,
+				},
+			},
+			wantedMessage: `{"store":"DOWN","delegate":{"state":"OPEN","consecutiveFailures":5,"openedAt":"2024-01-01T10:00:00Z"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extClientMock := Mock[service.ExtendedClient]()
+			When(extClientMock.Status()).ThenReturn(tt.status)
+
+			c := controller{
+				configClient: extClientMock,
+				logger:       zap.L(),
+				validator:    val,
+			}
+
+			w := httptest.NewRecorder()
+			ctx := getTestGinContext(w)
+			c.GetStatus(ctx)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.wantedMessage, w.Body.String())
 		})
 	}
 }
@@ -13,7 +13,7 @@ var (
 	errParsingRequestBody = errors.New("error reading request body")
 )
 
-func ParseRequestBody[V pb.Notification | model.Config](r io.Reader) (*V, error) {
+func ParseRequestBody[V pb.Notification | model.Config | model.Override](r io.Reader) (*V, error) {
 	jsonData, err := io.ReadAll(r)
 	if err != nil {
 		return nil, errors.Join(err, errReadingRequestBody)
@@ -1,12 +1,16 @@
 package http
 
 import (
+	"fmt"
 	locale "github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	translations "github.com/go-playground/validator/v10/translations/en"
 	"github.com/sebasir/rate-limiter-example/model"
 	"github.com/sebasir/rate-limiter-example/notification/proto"
+	"slices"
+	"strings"
+	"time"
 )
 
 type CustomValidator struct {
@@ -17,12 +21,6 @@ type CustomValidator struct {
 func GetValidator() *CustomValidator {
 	val := validator.New()
 
-	isTimeUnitTag := "time-unit"
-
-	if err := val.RegisterValidation(isTimeUnitTag, ValidateTimeUnit); err != nil {
-		return nil
-	}
-
 	en := locale.New()
 	uni := ut.New(en, en)
 	trans, _ := uni.GetTranslator("en")
@@ -31,21 +29,76 @@ func GetValidator() *CustomValidator {
 		return nil
 	}
 
-	if err := val.RegisterTranslation(isTimeUnitTag, trans,
-		func(ut ut.Translator) (err error) {
-			if err = ut.Add(isTimeUnitTag, "{0} must be one of SECOND, MINUTE, HOUR, DAY", false); err != nil {
-				return
-			}
+	if err := registerValidation(val, trans, "time-unit", ValidateTimeUnit,
+		fmt.Sprintf("{0} must be one of %s", strings.Join(model.TimeUnits, ", "))); err != nil {
+		return nil
+	}
 
-			return
-		}, func(ut ut.Translator, fe validator.FieldError) string {
-			t, err := ut.T(fe.Tag(), fe.Field())
-			if err != nil {
-				return fe.(error).Error()
-			}
+	if err := registerValidation(val, trans, "time-amount", ValidateTimeAmount,
+		"{0} must be 1 or greater"); err != nil {
+		return nil
+	}
 
-			return t
-		}); err != nil {
+	if err := registerValidation(val, trans, "window", ValidateWindow,
+		"{0} must be an ISO-8601 duration of whole seconds up to months (e.g. PT90M, P1D or P1M), replacing timeAmount and timeUnit"); err != nil {
+		return nil
+	}
+
+	if err := registerValidation(val, trans, "duration", ValidateDuration,
+		"{0} must be an ISO-8601 duration of whole seconds up to months (e.g. PT10M, P1D or P1M)"); err != nil {
+		return nil
+	}
+
+	if err := registerValidation(val, trans, "algorithm", ValidateAlgorithm,
+		fmt.Sprintf("{0} must be one of %s", strings.Join(model.Algorithms, ", "))); err != nil {
+		return nil
+	}
+
+	if err := registerValidation(val, trans, "mode", ValidateMode,
+		fmt.Sprintf("{0} must be one of %s", strings.Join(model.Modes, ", "))); err != nil {
+		return nil
+	}
+
+	if err := registerValidation(val, trans, "counting-policy", ValidateCountingPolicy,
+		fmt.Sprintf("{0} must be one of %s", strings.Join(model.CountingPolicies, ", "))); err != nil {
+		return nil
+	}
+
+	if err := registerValidation(val, trans, "overflow-policy", ValidateOverflowPolicy,
+		fmt.Sprintf("{0} must be one of %s", strings.Join(model.OverflowPolicies, ", "))); err != nil {
+		return nil
+	}
+
+	if err := registerValidation(val, trans, "degradation-policy", ValidateDegradationPolicy,
+		fmt.Sprintf("{0} must be one of %s", strings.Join(model.DegradationPolicies, ", "))); err != nil {
+		return nil
+	}
+
+	if err := registerValidation(val, trans, "alignment", ValidateAlignment,
+		fmt.Sprintf("{0} must be one of %s", strings.Join(model.Alignments, ", "))); err != nil {
+		return nil
+	}
+
+	if err := registerValidation(val, trans, "time-zone", ValidateTimeZone,
+		"{0} must be an IANA time zone"); err != nil {
+		return nil
+	}
+
+	if err := registerValidation(val, trans, "weekday", ValidateWeekday,
+		fmt.Sprintf("{0} must be one of %s", strings.Join(model.Weekdays, ", "))); err != nil {
+		return nil
+	}
+
+	if err := registerValidation(val, trans, "clock", ValidateClock,
+		"{0} must be a time of the day as HH:MM"); err != nil {
+		return nil
+	}
+
+	if err := registerTranslation(val, trans, "required_unless", "{0} is a required field"); err != nil {
+		return nil
+	}
+
+	if err := registerTranslation(val, trans, "required_without_all", "{0} is a required field"); err != nil {
 		return nil
 	}
 
@@ -61,6 +114,28 @@ func GetValidator() *CustomValidator {
 	}
 }
 
+func registerValidation(val *validator.Validate, trans ut.Translator, tag string, fn validator.Func, text string) error {
+	if err := val.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+
+	return registerTranslation(val, trans, tag, text)
+}
+
+func registerTranslation(val *validator.Validate, trans ut.Translator, tag string, text string) error {
+	return val.RegisterTranslation(tag, trans,
+		func(ut ut.Translator) error {
+			return ut.Add(tag, text, false)
+		}, func(ut ut.Translator, fe validator.FieldError) string {
+			t, err := ut.T(fe.Tag(), fe.Field())
+			if err != nil {
+				return fe.(error).Error()
+			}
+
+			return t
+		})
+}
+
 func (v *CustomValidator) Translate(err error) map[string]string {
 	return err.(validator.ValidationErrors).Translate(v.trans)
 }
@@ -69,8 +144,101 @@ func (v *CustomValidator) RegisterStructValidationMapRules(rules map[string]stri
 	v.Validate.RegisterStructValidationMapRules(rules, types...)
 }
 
+// ValidateTimeUnit accepts a known time unit, or none when the window is given as an ISO-8601
+// duration instead.
 func ValidateTimeUnit(fl validator.FieldLevel) bool {
 	val := fl.Field().String()
+	if val == "" && windowOf(fl) != "" {
+		return true
+	}
+
 	_, exists := model.TimeUnitMap[val]
 	return exists
 }
+
+// ValidateTimeAmount accepts 1 or greater, or none when the window is given as an ISO-8601 duration
+// instead.
+func ValidateTimeAmount(fl validator.FieldLevel) bool {
+	val := fl.Field().Int()
+	return val >= 1 || (val == 0 && windowOf(fl) != "")
+}
+
+// ValidateWindow accepts an ISO-8601 duration, when neither the time amount nor the unit are given.
+func ValidateWindow(fl validator.FieldLevel) bool {
+	val := fl.Field().String()
+	if val == "" {
+		return true
+	}
+
+	parent := fl.Parent()
+	if parent.FieldByName("TimeAmount").Int() != 0 || parent.FieldByName("TimeUnit").String() != "" {
+		return false
+	}
+
+	_, _, err := model.ParseISODuration(val)
+	return err == nil
+}
+
+func ValidateDuration(fl validator.FieldLevel) bool {
+	val := fl.Field().String()
+	if val == "" {
+		return true
+	}
+
+	_, _, err := model.ParseISODuration(val)
+	return err == nil
+}
+
+func ValidateWeekday(fl validator.FieldLevel) bool {
+	return slices.Contains(model.Weekdays, fl.Field().String())
+}
+
+func ValidateClock(fl validator.FieldLevel) bool {
+	_, err := time.Parse(model.ClockLayout, fl.Field().String())
+	return err == nil
+}
+
+// windowOf returns the ISO-8601 window next to the validated field, if its struct has one.
+func windowOf(fl validator.FieldLevel) string {
+	window := fl.Parent().FieldByName("Window")
+	if !window.IsValid() {
+		return ""
+	}
+
+	return window.String()
+}
+
+func ValidateAlgorithm(fl validator.FieldLevel) bool {
+	val := fl.Field().String()
+	return val == "" || slices.Contains(model.Algorithms, val)
+}
+
+func ValidateMode(fl validator.FieldLevel) bool {
+	val := fl.Field().String()
+	return val == "" || slices.Contains(model.Modes, val)
+}
+
+func ValidateCountingPolicy(fl validator.FieldLevel) bool {
+	val := fl.Field().String()
+	return val == "" || slices.Contains(model.CountingPolicies, val)
+}
+
+func ValidateOverflowPolicy(fl validator.FieldLevel) bool {
+	val := fl.Field().String()
+	return val == "" || slices.Contains(model.OverflowPolicies, val)
+}
+
+func ValidateDegradationPolicy(fl validator.FieldLevel) bool {
+	val := fl.Field().String()
+	return val == "" || slices.Contains(model.DegradationPolicies, val)
+}
+
+func ValidateAlignment(fl validator.FieldLevel) bool {
+	val := fl.Field().String()
+	return val == "" || slices.Contains(model.Alignments, val)
+}
+
+func ValidateTimeZone(fl validator.FieldLevel) bool {
+	_, err := time.LoadLocation(fl.Field().String())
+	return err == nil
+}
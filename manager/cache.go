@@ -0,0 +1,318 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis"
+	"github.com/sebasir/rate-limiter-example/model"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// CachedService is a Service keeping the notification configs and recipient overrides it reads in
+// memory for a while.
+type CachedService interface {
+	Service
+	// Invalidate drops the config called name from the cache.
+	Invalidate(name string)
+	// Listen invalidates the configs named by the messages of the invalidation channel, published
+	// by every instance persisting a config, until the context is done. Messages of the override
+	// invalidation channel invalidate the overrides of the recipient they name instead.
+	Listen(ctx context.Context, messages <-chan *redis.Message)
+}
+
+type cachedConfig struct {
+	config    *model.Config
+	err       error
+	expiresAt time.Time
+}
+
+// cachedOverrides are every override of a recipient, so the lookups of all of its notification
+// types are served by a single entry.
+type cachedOverrides struct {
+	overrides []*model.Override
+	expiresAt time.Time
+}
+
+type cachedClient struct {
+	Service
+	rdb       redis.Cmdable
+	ttl       time.Duration
+	mu        sync.RWMutex
+	configs   map[string]*cachedConfig
+	overrides map[string]*cachedOverrides
+	nextSweep time.Time
+	// generation changes on every invalidation, so configs or overrides loaded meanwhile are not
+	// cached stale.
+	generation uint64
+	logger     *zap.Logger
+}
+
+// NewCachedClient wraps the service with a read-through cache of the configs and overrides, lasting
+// ttl at most even when an invalidation is missed (e.g. while reconnecting to the invalidation
+// channel).
+func NewCachedClient(delegate Service, rdb redis.Cmdable, ttl time.Duration) CachedService {
+	return &cachedClient{
+		Service:   delegate,
+		rdb:       rdb,
+		ttl:       ttl,
+		configs:   make(map[string]*cachedConfig),
+		overrides: make(map[string]*cachedOverrides),
+		logger:    zap.L(),
+	}
+}
+
+// GetByName returns a copy of the cached config, loading it when missing or expired. Configs not
+// found are cached as well, as the group caps are looked up on every send whether they exist or not.
+func (c *cachedClient) GetByName(name string) (*model.Config, error) {
+	current := time.Now()
+
+	c.mu.RLock()
+	cached, exists := c.configs[name]
+	generation := c.generation
+	c.mu.RUnlock()
+
+	if !exists || !current.Before(cached.expiresAt) {
+		c.logger.Debug("notification config cache miss", zap.String("name", name))
+		config, err := c.Service.GetByName(name)
+		if err != nil && !errors.Is(err, ErrNotificationConfigNotFound) {
+			return nil, err
+		}
+
+		cached = &cachedConfig{
+			config:    config,
+			err:       err,
+			expiresAt: current.Add(c.ttl),
+		}
+
+		c.mu.Lock()
+		if generation == c.generation {
+			c.sweep(current)
+			c.configs[name] = cached
+		}
+		c.mu.Unlock()
+	}
+
+	if cached.err != nil {
+		return nil, cached.err
+	}
+
+	config := *cached.config
+	return &config, nil
+}
+
+// GetByNames returns copies of the cached configs found among the names, loading the ones missing or
+// expired at once. Configs not found are cached as well, as GetByName does.
+func (c *cachedClient) GetByNames(names []string) (map[string]*model.Config, error) {
+	current := time.Now()
+	configs := make(map[string]*model.Config, len(names))
+	missing := make([]string, 0, len(names))
+
+	c.mu.RLock()
+	for _, name := range names {
+		cached, exists := c.configs[name]
+		if !exists || !current.Before(cached.expiresAt) {
+			missing = append(missing, name)
+			continue
+		}
+
+		if cached.err == nil {
+			config := *cached.config
+			configs[name] = &config
+		}
+	}
+	generation := c.generation
+	c.mu.RUnlock()
+
+	if len(missing) == 0 {
+		return configs, nil
+	}
+
+	c.logger.Debug("notification config cache miss", zap.Strings("names", missing))
+	loaded, err := c.Service.GetByNames(missing)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if generation == c.generation {
+		c.sweep(current)
+		for _, name := range missing {
+			cached := &cachedConfig{
+				config:    loaded[name],
+				expiresAt: current.Add(c.ttl),
+			}
+
+			if cached.config == nil {
+				cached.err = errors.Join(ErrNotificationConfigNotFound, ErrOperatingNotificationConfig)
+			}
+			c.configs[name] = cached
+		}
+	}
+	c.mu.Unlock()
+
+	for name, loadedConfig := range loaded {
+		config := *loadedConfig
+		configs[name] = &config
+	}
+
+	return configs, nil
+}
+
+// PersistNotificationConfig persists the config, and publishes its name so every instance drops it
+// from its cache.
+func (c *cachedClient) PersistNotificationConfig(config *model.Config) error {
+	if err := c.Service.PersistNotificationConfig(config); err != nil {
+		return err
+	}
+
+	c.Invalidate(config.Name)
+	if err := c.rdb.Publish(model.ConfigInvalidationChannel, config.Name).Err(); err != nil {
+		c.logger.Warn("error publishing notification config invalidation, other instances will reload it on expiry",
+			zap.Error(err), zap.String("name", config.Name))
+	}
+
+	return nil
+}
+
+// GetOverride returns a copy of the cached override of the recipient for the notification type,
+// loading every override of the recipient when missing or expired, as GetOverride is called on
+// every send whether the recipient has overrides or not.
+func (c *cachedClient) GetOverride(recipient, notificationType string) (*model.Override, error) {
+	current := time.Now()
+
+	c.mu.RLock()
+	cached, exists := c.overrides[recipient]
+	generation := c.generation
+	c.mu.RUnlock()
+
+	if !exists || !current.Before(cached.expiresAt) {
+		c.logger.Debug("recipient override cache miss", zap.String("recipient", recipient))
+		overrides, err := c.Service.ListRecipientOverrides(recipient)
+		if err != nil {
+			return nil, err
+		}
+
+		cached = &cachedOverrides{
+			overrides: overrides,
+			expiresAt: current.Add(c.ttl),
+		}
+
+		c.mu.Lock()
+		if generation == c.generation {
+			c.sweep(current)
+			c.overrides[recipient] = cached
+		}
+		c.mu.Unlock()
+	}
+
+	var found *model.Override
+	for _, override := range cached.overrides {
+		switch override.Scope() {
+		case notificationType:
+			found = override
+		case model.AllNotificationTypes:
+			if found == nil {
+				found = override
+			}
+		}
+	}
+
+	if found == nil {
+		return nil, nil
+	}
+
+	override := *found
+	return &override, nil
+}
+
+// PersistRecipientOverride persists the override, and publishes its recipient so every instance
+// drops the overrides of the recipient from its cache.
+func (c *cachedClient) PersistRecipientOverride(override *model.Override) error {
+	if err := c.Service.PersistRecipientOverride(override); err != nil {
+		return err
+	}
+
+	c.invalidateOverrides(override.Recipient)
+	return nil
+}
+
+// DeleteRecipientOverride deletes the override, invalidating the overrides of its recipient like
+// PersistRecipientOverride does.
+func (c *cachedClient) DeleteRecipientOverride(recipient, notificationType string) error {
+	if err := c.Service.DeleteRecipientOverride(recipient, notificationType); err != nil {
+		return err
+	}
+
+	c.invalidateOverrides(recipient)
+	return nil
+}
+
+func (c *cachedClient) invalidateOverrides(recipient string) {
+	c.evictOverrides(recipient)
+	if err := c.rdb.Publish(model.OverrideInvalidationChannel, recipient).Err(); err != nil {
+		c.logger.Warn("error publishing recipient override invalidation, other instances will reload it on expiry",
+			zap.Error(err), zap.String("recipient", recipient))
+	}
+}
+
+func (c *cachedClient) evictOverrides(recipient string) {
+	c.logger.Debug("invalidating cached recipient overrides", zap.String("recipient", recipient))
+
+	c.mu.Lock()
+	delete(c.overrides, recipient)
+	c.generation++
+	c.mu.Unlock()
+}
+
+func (c *cachedClient) Invalidate(name string) {
+	c.logger.Debug("invalidating cached notification config", zap.String("name", name))
+
+	c.mu.Lock()
+	delete(c.configs, name)
+	c.generation++
+	c.mu.Unlock()
+}
+
+func (c *cachedClient) Listen(ctx context.Context, messages <-chan *redis.Message) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			if message.Channel == model.OverrideInvalidationChannel {
+				c.evictOverrides(message.Payload)
+				continue
+			}
+
+			c.Invalidate(message.Payload)
+		}
+	}
+}
+
+// sweep drops the expired configs and overrides once per ttl, so names looked up only once (e.g.
+// unknown types) do not pile up. Callers hold the lock.
+func (c *cachedClient) sweep(current time.Time) {
+	if current.Before(c.nextSweep) {
+		return
+	}
+
+	for name, cached := range c.configs {
+		if !current.Before(cached.expiresAt) {
+			delete(c.configs, name)
+		}
+	}
+
+	for recipient, cached := range c.overrides {
+		if !current.Before(cached.expiresAt) {
+			delete(c.overrides, recipient)
+		}
+	}
+
+	c.nextSweep = current.Add(c.ttl)
+}
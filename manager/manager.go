@@ -6,11 +6,16 @@ import (
 	"github.com/go-redis/redis"
 	. "github.com/sebasir/rate-limiter-example/app_errors"
 	"github.com/sebasir/rate-limiter-example/model"
+	"github.com/sebasir/rate-limiter-example/redis_cluster"
 	"go.uber.org/zap"
+	"strconv"
+	"sync"
 )
 
 var ErrOperatingNotificationConfig = errors.New("error operating notification config")
 
+var ErrNotificationConfigNotFound = errors.New("notification config not found")
+
 type client struct {
 	rdb    redis.Cmdable
 	logger *zap.Logger
@@ -26,34 +31,23 @@ func NewClient(rdb redis.Cmdable) Service {
 func (c *client) ListNotificationConfig() ([]*model.Config, error) {
 	c.logger.Debug("retrieving notification config list")
 
-	scanKey := fmtKey("*")
-	var cursor uint64
-	allKeys := make([]*model.Config, 0)
-	for {
-		foundSet, cursor, err := c.rdb.Scan(cursor, scanKey, 50).Result()
-		if err != nil {
-			return nil, LogAndError("error retrieving notification config list",
-				errors.Join(err, ErrOperatingNotificationConfig), c.logger)
-		}
-
-		tempConfigs := make([]*model.Config, len(foundSet))
-		for i, value := range foundSet {
-			config, err := c.getByKey(value)
-			if err != nil {
-				return nil, LogAndError("error retrieving notification config from key",
-					err, c.logger, zap.String("value", value))
-			}
-			tempConfigs[i] = config
-		}
-
-		allKeys = append(allKeys, tempConfigs...)
+	keys, err := c.scanKeys(fmtKey("*"))
+	if err != nil {
+		return nil, LogAndError("error retrieving notification config list",
+			errors.Join(err, ErrOperatingNotificationConfig), c.logger)
+	}
 
-		if cursor == 0 {
-			break
+	configs := make([]*model.Config, len(keys))
+	for i, key := range keys {
+		config, err := c.getByKey(key)
+		if err != nil {
+			return nil, LogAndError("error retrieving notification config from key",
+				err, c.logger, zap.String("value", key))
 		}
+		configs[i] = config
 	}
 
-	return allKeys, nil
+	return configs, nil
 }
 
 func (c *client) GetByName(name string) (*model.Config, error) {
@@ -62,6 +56,36 @@ func (c *client) GetByName(name string) (*model.Config, error) {
 	return c.getByKey(fmtKey(name))
 }
 
+// GetByNames pipelines the lookups of every name, so they take a single round trip even on a Redis
+// Cluster, where the keys of the configs land in different slots.
+func (c *client) GetByNames(names []string) (map[string]*model.Config, error) {
+	c.logger.Debug("retrieving notification configs from names", zap.Strings("names", names))
+
+	cmds := make([]*redis.StringCmd, len(names))
+	// the errors of the pipeline are the ones of its commands, checked one by one
+	_, _ = c.rdb.Pipelined(func(pipe redis.Pipeliner) error {
+		for i, name := range names {
+			cmds[i] = pipe.Get(fmtKey(name))
+		}
+		return nil
+	})
+
+	configs := make(map[string]*model.Config, len(names))
+	for i, name := range names {
+		config, err := c.configFromCmd(fmtKey(name), cmds[i])
+		if errors.Is(err, ErrNotificationConfigNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		configs[name] = config
+	}
+
+	return configs, nil
+}
+
 func (c *client) PersistNotificationConfig(config *model.Config) error {
 	configField := zap.String("name", config.Name)
 	c.logger.Debug("persisting notification config", configField)
@@ -81,10 +105,52 @@ func (c *client) PersistNotificationConfig(config *model.Config) error {
 	return nil
 }
 
+func (c *client) ListShadowRejections() (map[string]int64, error) {
+	c.logger.Debug("retrieving shadow rejection counts")
+
+	values, err := c.rdb.HGetAll(model.ShadowRejectionSet).Result()
+	if err != nil {
+		return nil, LogAndError("error retrieving shadow rejection counts",
+			errors.Join(err, ErrOperatingNotificationConfig), c.logger)
+	}
+
+	counts := make(map[string]int64, len(values))
+	for name, value := range values {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, LogAndError("error parsing shadow rejection count from DB",
+				errors.Join(err, ErrOperatingNotificationConfig), c.logger, zap.String("name", name))
+		}
+		counts[name] = count
+	}
+
+	return counts, nil
+}
+
+func (c *client) IncrementShadowRejections(name string) error {
+	nameField := zap.String("name", name)
+	c.logger.Debug("incrementing shadow rejection count", nameField)
+
+	if err := c.rdb.HIncrBy(model.ShadowRejectionSet, name, 1).Err(); err != nil {
+		return LogAndError("error incrementing shadow rejection count",
+			errors.Join(err, ErrOperatingNotificationConfig), c.logger, nameField)
+	}
+
+	return nil
+}
+
 func (c *client) getByKey(key string) (*model.Config, error) {
+	return c.configFromCmd(key, c.rdb.Get(key))
+}
+
+func (c *client) configFromCmd(key string, strCmd *redis.StringCmd) (*model.Config, error) {
 	keyField := zap.String("key", key)
-	strCmd := c.rdb.Get(key)
 	if err := strCmd.Err(); err != nil {
+		if errors.Is(err, redis.Nil) {
+			c.logger.Debug("notification config not found", keyField)
+			return nil, errors.Join(err, ErrNotificationConfigNotFound, ErrOperatingNotificationConfig)
+		}
+
 		return nil, LogAndError("error retrieving notification config from key",
 			errors.Join(err, ErrOperatingNotificationConfig), c.logger, keyField)
 	}
@@ -98,6 +164,31 @@ func (c *client) getByKey(key string) (*model.Config, error) {
 	return config, nil
 }
 
+// scanKeys collects the keys matching the pattern, scanning every master of a Redis Cluster.
+func (c *client) scanKeys(pattern string) ([]string, error) {
+	var mu sync.Mutex
+	keys := make([]string, 0)
+	err := redis_cluster.ForEachMaster(c.rdb, func(node redis.Cmdable) error {
+		var cursor uint64
+		for {
+			found, next, err := node.Scan(cursor, pattern, 50).Result()
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			keys = append(keys, found...)
+			mu.Unlock()
+
+			if cursor = next; cursor == 0 {
+				return nil
+			}
+		}
+	})
+
+	return keys, err
+}
+
 func fmtKey(key string) string {
 	return fmt.Sprintf("%s:%s", model.NotificationConfigSet, key)
 }
@@ -1,6 +1,7 @@
 package manager
 
 import (
+	"context"
 	"errors"
 	"github.com/go-redis/redis"
 	. "github.com/ovechkin-dm/mockio/mock"
@@ -129,6 +130,16 @@ func Test_client_GetByName(t *testing.T) {
 			args:      "News",
 			wantErr:   true,
 			targetErr: ErrOperatingNotificationConfig,
+		}, {
+			name: "ERROR_Config_Not_Found",
+			rdb: (&redisMock{
+				getCmd:  redisCmd[strings]{err: redis.Nil},
+				setCmd:  redisCmd[string]{exclude: true},
+				scanCmd: redisCmd[scanRes]{exclude: true},
+			}).buildRedisMock(),
+			args:      "GLOBAL",
+			wantErr:   true,
+			targetErr: ErrNotificationConfigNotFound,
 		},
 	}
 	for _, tt := range tests {
@@ -155,6 +166,64 @@ func Test_client_GetByName(t *testing.T) {
 	}
 }
 
+func Test_client_GetByNames(t *testing.T) {
+	SetUp(t)
+
+	tests := []struct {
+		name      string
+		getErr    error
+		want      map[string]*model.Config
+		wantErr   bool
+		targetErr error
+	}{
+		{
+			name: "OK_Configs_Found_Retrieved",
+			want: map[string]*model.Config{
+				"Marketing": configMap[fmtKey("Marketing")],
+			},
+		}, {
+			name:      "ERROR_Redis_Get",
+			getErr:    redisErr,
+			wantErr:   true,
+			targetErr: ErrOperatingNotificationConfig,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeMock := Mock[redis.Pipeliner]()
+			When(pipeMock.Get(fmtKey("Marketing"))).
+				ThenReturn(redis.NewStringResult(configStrMap[fmtKey("Marketing")], tt.getErr))
+			When(pipeMock.Get(fmtKey("GLOBAL"))).
+				ThenReturn(redis.NewStringResult("", redis.Nil))
+
+			rdbMock := Mock[redis.Cmdable]()
+			WhenDouble(rdbMock.Pipelined(Any[func(redis.Pipeliner) error]())).
+				ThenAnswer(func(args []any) ([]redis.Cmder, error) {
+					return nil, args[0].(func(redis.Pipeliner) error)(pipeMock)
+				})
+
+			c := &client{
+				rdb:    rdbMock,
+				logger: zap.L(),
+			}
+			got, err := c.GetByNames([]string{"Marketing", "GLOBAL"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetByNames() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr && !errors.Is(err, tt.targetErr) {
+				t.Errorf("GetByNames() error = %v, targetErr = %v", err, tt.targetErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetByNames() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_client_ListNotificationConfig(t *testing.T) {
 	SetUp(t)
 
@@ -283,3 +352,862 @@ func Test_client_PersistNotificationConfig(t *testing.T) {
 		})
 	}
 }
+
+func Test_client_ListShadowRejections(t *testing.T) {
+	SetUp(t)
+
+	tests := []struct {
+		name    string
+		values  map[string]string
+		err     error
+		want    map[string]int64
+		wantErr bool
+	}{
+		{
+			name:   "OK_Counts_Retrieved",
+			values: map[string]string{"News": "3", "Status": "1"},
+			want:   map[string]int64{"News": 3, "Status": 1},
+		}, {
+			name:    "ERROR_Invalid_Count",
+			values:  map[string]string{"News": "three"},
+			wantErr: true,
+		}, {
+			name:    "ERROR_Redis_HGetAll",
+			err:     redisErr,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdbMock := Mock[redis.Cmdable]()
+			When(rdbMock.HGetAll(model.ShadowRejectionSet)).
+				ThenReturn(redis.NewStringStringMapResult(tt.values, tt.err))
+
+			c := &client{
+				rdb:    rdbMock,
+				logger: zap.L(),
+			}
+			got, err := c.ListShadowRejections()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ListShadowRejections() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr && !errors.Is(err, ErrOperatingNotificationConfig) {
+				t.Errorf("ListShadowRejections() error = %v, targetErr = %v", err, ErrOperatingNotificationConfig)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListShadowRejections() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_client_IncrementShadowRejections(t *testing.T) {
+	SetUp(t)
+
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{
+			name: "OK_Count_Incremented",
+		}, {
+			name:    "ERROR_Redis_HIncrBy",
+			err:     redisErr,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdbMock := Mock[redis.Cmdable]()
+			When(rdbMock.HIncrBy(model.ShadowRejectionSet, "News", int64(1))).
+				ThenReturn(redis.NewIntResult(1, tt.err))
+
+			c := &client{
+				rdb:    rdbMock,
+				logger: zap.L(),
+			}
+			if err := c.IncrementShadowRejections("News"); (err != nil) != tt.wantErr {
+				t.Errorf("IncrementShadowRejections() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type overrideRedisMock struct {
+	hGetAllCmd redisCmd[string]
+	hmGetCmd   redisCmd[strings]
+	hSetCmd    redisCmd[string]
+	hDelCmd    redisCmd[string]
+}
+
+func (r *overrideRedisMock) buildRedisMock() redis.Cmdable {
+	rdbMock := Mock[redis.Cmdable]()
+
+	if !r.hGetAllCmd.exclude {
+		values := make(map[string]string)
+		if r.hGetAllCmd.val != "" {
+			values[model.AllNotificationTypes] = r.hGetAllCmd.val
+		}
+
+		When(rdbMock.HGetAll(AnyString())).
+			ThenReturn(redis.NewStringStringMapResult(values, r.hGetAllCmd.err))
+	}
+
+	if !r.hmGetCmd.exclude {
+		values := make([]interface{}, len(r.hmGetCmd.val))
+		for i, value := range r.hmGetCmd.val {
+			if value != "" {
+				values[i] = value
+			}
+		}
+
+		When(rdbMock.HMGet(AnyString(), Any[[]string]()...)).
+			ThenReturn(redis.NewSliceResult(values, r.hmGetCmd.err))
+	}
+
+	if !r.hSetCmd.exclude {
+		When(rdbMock.HSet(AnyString(), AnyString(), AnyInterface())).
+			ThenReturn(redis.NewBoolResult(true, r.hSetCmd.err))
+	}
+
+	if !r.hDelCmd.exclude {
+		deleted := int64(0)
+		if r.hDelCmd.val != "" {
+			deleted = 1
+		}
+
+		When(rdbMock.HDel(AnyString(), Any[[]string]()...)).
+			ThenReturn(redis.NewIntResult(deleted, r.hDelCmd.err))
+	}
+
+	return rdbMock
+}
+
+var (
+	overrideStr = `{"recipient":"a@a.a","limitCount":10,"timeAmount":1,"timeUnit":"HOUR"}`
+
+	override = &model.Override{
+		Recipient:  "a@a.a",
+		LimitCount: 10,
+		TimeAmount: 1,
+		TimeUnit:   "HOUR",
+	}
+)
+
+func Test_client_GetOverride(t *testing.T) {
+	SetUp(t)
+
+	tests := []testCase[*model.Override, string]{
+		{
+			name: "OK_Type_Override_Retrieved",
+			rdb: (&overrideRedisMock{
+				hGetAllCmd: redisCmd[string]{exclude: true},
+				hmGetCmd:   redisCmd[strings]{val: strings{overrideStr, ""}},
+				hSetCmd:    redisCmd[string]{exclude: true},
+				hDelCmd:    redisCmd[string]{exclude: true},
+			}).buildRedisMock(),
+			args: "News",
+			want: override,
+		}, {
+			name: "OK_Recipient_Override_Retrieved",
+			rdb: (&overrideRedisMock{
+				hGetAllCmd: redisCmd[string]{exclude: true},
+				hmGetCmd:   redisCmd[strings]{val: strings{"", overrideStr}},
+				hSetCmd:    redisCmd[string]{exclude: true},
+				hDelCmd:    redisCmd[string]{exclude: true},
+			}).buildRedisMock(),
+			args: "News",
+			want: override,
+		}, {
+			name: "OK_No_Override",
+			rdb: (&overrideRedisMock{
+				hGetAllCmd: redisCmd[string]{exclude: true},
+				hmGetCmd:   redisCmd[strings]{val: strings{"", ""}},
+				hSetCmd:    redisCmd[string]{exclude: true},
+				hDelCmd:    redisCmd[string]{exclude: true},
+			}).buildRedisMock(),
+			args: "News",
+		}, {
+			name: "ERROR_Invalid_Override",
+			rdb: (&overrideRedisMock{
+				hGetAllCmd: redisCmd[string]{exclude: true},
+				hmGetCmd:   redisCmd[strings]{val: strings{"{", ""}},
+				hSetCmd:    redisCmd[string]{exclude: true},
+				hDelCmd:    redisCmd[string]{exclude: true},
+			}).buildRedisMock(),
+			args:      "News",
+			wantErr:   true,
+			targetErr: ErrOperatingRecipientOverride,
+		}, {
+			name: "ERROR_Redis_HMGet",
+			rdb: (&overrideRedisMock{
+				hGetAllCmd: redisCmd[string]{exclude: true},
+				hmGetCmd:   redisCmd[strings]{err: redisErr},
+				hSetCmd:    redisCmd[string]{exclude: true},
+				hDelCmd:    redisCmd[string]{exclude: true},
+			}).buildRedisMock(),
+			args:      "News",
+			wantErr:   true,
+			targetErr: ErrOperatingRecipientOverride,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &client{
+				rdb:    tt.rdb,
+				logger: zap.L(),
+			}
+			got, err := c.GetOverride("a@a.a", tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetOverride() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr && !errors.Is(err, tt.targetErr) {
+				t.Errorf("GetOverride() error = %v, targetErr = %v", err, tt.targetErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetOverride() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_client_ListRecipientOverrides(t *testing.T) {
+	SetUp(t)
+
+	tests := []testCase[[]*model.Override, string]{
+		{
+			name: "OK_Overrides_Retrieved",
+			rdb: (&overrideRedisMock{
+				hGetAllCmd: redisCmd[string]{val: overrideStr},
+				hmGetCmd:   redisCmd[strings]{exclude: true},
+				hSetCmd:    redisCmd[string]{exclude: true},
+				hDelCmd:    redisCmd[string]{exclude: true},
+			}).buildRedisMock(),
+			args: "a@a.a",
+			want: []*model.Override{override},
+		}, {
+			name: "OK_No_Overrides_Retrieved",
+			rdb: (&overrideRedisMock{
+				hmGetCmd: redisCmd[strings]{exclude: true},
+				hSetCmd:  redisCmd[string]{exclude: true},
+				hDelCmd:  redisCmd[string]{exclude: true},
+			}).buildRedisMock(),
+			args: "a@a.a",
+			want: []*model.Override{},
+		}, {
+			name: "ERROR_Redis_HGetAll",
+			rdb: (&overrideRedisMock{
+				hGetAllCmd: redisCmd[string]{err: redisErr},
+				hmGetCmd:   redisCmd[strings]{exclude: true},
+				hSetCmd:    redisCmd[string]{exclude: true},
+				hDelCmd:    redisCmd[string]{exclude: true},
+			}).buildRedisMock(),
+			args:      "a@a.a",
+			wantErr:   true,
+			targetErr: ErrOperatingRecipientOverride,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &client{
+				rdb:    tt.rdb,
+				logger: zap.L(),
+			}
+			got, err := c.ListRecipientOverrides(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ListRecipientOverrides() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr && !errors.Is(err, tt.targetErr) {
+				t.Errorf("ListRecipientOverrides() error = %v, targetErr = %v", err, tt.targetErr)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListRecipientOverrides() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_client_PersistRecipientOverride(t *testing.T) {
+	SetUp(t)
+
+	tests := []testCase[any, *model.Override]{
+		{
+			name: "OK_Override_Persisted",
+			rdb: (&overrideRedisMock{
+				hGetAllCmd: redisCmd[string]{exclude: true},
+				hmGetCmd:   redisCmd[strings]{exclude: true},
+				hDelCmd:    redisCmd[string]{exclude: true},
+			}).buildRedisMock(),
+			args: override,
+		}, {
+			name: "ERROR_Redis_HSet",
+			rdb: (&overrideRedisMock{
+				hGetAllCmd: redisCmd[string]{exclude: true},
+				hmGetCmd:   redisCmd[strings]{exclude: true},
+				hSetCmd:    redisCmd[string]{err: redisErr},
+				hDelCmd:    redisCmd[string]{exclude: true},
+			}).buildRedisMock(),
+			args:      override,
+			wantErr:   true,
+			targetErr: ErrOperatingRecipientOverride,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &client{
+				rdb:    tt.rdb,
+				logger: zap.L(),
+			}
+
+			err := c.PersistRecipientOverride(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PersistRecipientOverride() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr && !errors.Is(err, tt.targetErr) {
+				t.Errorf("PersistRecipientOverride() error = %v, targetErr = %v", err, tt.targetErr)
+			}
+		})
+	}
+}
+
+func Test_client_DeleteRecipientOverride(t *testing.T) {
+	SetUp(t)
+
+	tests := []testCase[any, string]{
+		{
+			name: "OK_Override_Deleted",
+			rdb: (&overrideRedisMock{
+				hGetAllCmd: redisCmd[string]{exclude: true},
+				hmGetCmd:   redisCmd[strings]{exclude: true},
+				hSetCmd:    redisCmd[string]{exclude: true},
+				hDelCmd:    redisCmd[string]{val: model.AllNotificationTypes},
+			}).buildRedisMock(),
+		}, {
+			name: "ERROR_Override_Not_Found",
+			rdb: (&overrideRedisMock{
+				hGetAllCmd: redisCmd[string]{exclude: true},
+				hmGetCmd:   redisCmd[strings]{exclude: true},
+				hSetCmd:    redisCmd[string]{exclude: true},
+			}).buildRedisMock(),
+			args:      "News",
+			wantErr:   true,
+			targetErr: ErrRecipientOverrideNotFound,
+		}, {
+			name: "ERROR_Redis_HDel",
+			rdb: (&overrideRedisMock{
+				hGetAllCmd: redisCmd[string]{exclude: true},
+				hmGetCmd:   redisCmd[strings]{exclude: true},
+				hSetCmd:    redisCmd[string]{exclude: true},
+				hDelCmd:    redisCmd[string]{err: redisErr},
+			}).buildRedisMock(),
+			wantErr:   true,
+			targetErr: ErrOperatingRecipientOverride,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &client{
+				rdb:    tt.rdb,
+				logger: zap.L(),
+			}
+
+			err := c.DeleteRecipientOverride("a@a.a", tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DeleteRecipientOverride() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr && !errors.Is(err, tt.targetErr) {
+				t.Errorf("DeleteRecipientOverride() error = %v, targetErr = %v", err, tt.targetErr)
+			}
+		})
+	}
+}
+
+func Test_memoryClient_Configs(t *testing.T) {
+	c := NewInMemoryClient()
+
+	if _, err := c.GetByName("Newsletter"); !errors.Is(err, ErrNotificationConfigNotFound) {
+		t.Errorf("GetByName() error = %v, targetErr = %v", err, ErrNotificationConfigNotFound)
+	}
+
+	configs := []*model.Config{
+		{Name: "Status", LimitCount: 2, TimeAmount: 1, TimeUnit: "MINUTE"},
+		{Name: "Newsletter", LimitCount: 1, TimeAmount: 1, TimeUnit: "DAY"},
+	}
+	for _, config := range configs {
+		if err := c.PersistNotificationConfig(config); err != nil {
+			t.Fatalf("PersistNotificationConfig() error = %v", err)
+		}
+	}
+
+	// the stored config is a copy, unaffected by later changes of the persisted one
+	configs[0].LimitCount = 10
+
+	got, err := c.GetByName("Status")
+	if err != nil {
+		t.Fatalf("GetByName() error = %v", err)
+	}
+
+	if got.LimitCount != 2 {
+		t.Errorf("GetByName() got = %v, want limit count 2", got)
+	}
+
+	found, err := c.GetByNames([]string{"Status", "GLOBAL"})
+	if err != nil {
+		t.Fatalf("GetByNames() error = %v", err)
+	}
+
+	if len(found) != 1 || found["Status"] == nil {
+		t.Errorf("GetByNames() got = %v, want Status only", found)
+	}
+
+	list, err := c.ListNotificationConfig()
+	if err != nil {
+		t.Fatalf("ListNotificationConfig() error = %v", err)
+	}
+
+	if len(list) != 2 || list[0].Name != "Newsletter" || list[1].Name != "Status" {
+		t.Errorf("ListNotificationConfig() got = %v, want Newsletter and Status", list)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.IncrementShadowRejections("Status"); err != nil {
+			t.Fatalf("IncrementShadowRejections() error = %v", err)
+		}
+	}
+
+	counts, err := c.ListShadowRejections()
+	if err != nil {
+		t.Fatalf("ListShadowRejections() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(counts, map[string]int64{"Status": 2}) {
+		t.Errorf("ListShadowRejections() got = %v, want %v", counts, map[string]int64{"Status": 2})
+	}
+}
+
+func Test_memoryClient_Overrides(t *testing.T) {
+	c := NewInMemoryClient()
+
+	typeOverride := &model.Override{Recipient: "a@a.a", NotificationType: "News", LimitCount: 5, TimeAmount: 1, TimeUnit: "DAY"}
+	allOverride := &model.Override{Recipient: "a@a.a", Exempt: true}
+	for _, override := range []*model.Override{typeOverride, allOverride} {
+		if err := c.PersistRecipientOverride(override); err != nil {
+			t.Fatalf("PersistRecipientOverride() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name             string
+		notificationType string
+		want             *model.Override
+	}{
+		{
+			name:             "OK_Type_Override",
+			notificationType: "News",
+			want:             typeOverride,
+		}, {
+			name:             "OK_Fallback_To_All_Types_Override",
+			notificationType: "Status",
+			want:             allOverride,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.GetOverride("a@a.a", tt.notificationType)
+			if err != nil {
+				t.Errorf("GetOverride() error = %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetOverride() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	list, err := c.ListRecipientOverrides("a@a.a")
+	if err != nil {
+		t.Fatalf("ListRecipientOverrides() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(list, []*model.Override{allOverride, typeOverride}) {
+		t.Errorf("ListRecipientOverrides() got = %v, want %v", list, []*model.Override{allOverride, typeOverride})
+	}
+
+	if err := c.DeleteRecipientOverride("a@a.a", ""); err != nil {
+		t.Errorf("DeleteRecipientOverride() error = %v", err)
+	}
+
+	if err := c.DeleteRecipientOverride("a@a.a", ""); !errors.Is(err, ErrRecipientOverrideNotFound) {
+		t.Errorf("DeleteRecipientOverride() error = %v, targetErr = %v", err, ErrRecipientOverrideNotFound)
+	}
+
+	if got, _ := c.GetOverride("a@a.a", "Status"); got != nil {
+		t.Errorf("GetOverride() got = %v, want nil", got)
+	}
+}
+
+func Test_cachedClient_GetByName(t *testing.T) {
+	SetUp(t)
+
+	config := &model.Config{Name: "Newsletter", LimitCount: 1, TimeAmount: 1, TimeUnit: "DAY"}
+	notFoundErr := errors.Join(redis.Nil, ErrNotificationConfigNotFound, ErrOperatingNotificationConfig)
+
+	tests := []struct {
+		name      string
+		config    *model.Config
+		err       error
+		ttl       time.Duration
+		wantCalls int
+		wantErr   bool
+		targetErr error
+	}{
+		{
+			name:      "OK_Config_Cached",
+			config:    config,
+			ttl:       time.Minute,
+			wantCalls: 1,
+		}, {
+			name:      "OK_Not_Found_Cached",
+			err:       notFoundErr,
+			ttl:       time.Minute,
+			wantCalls: 1,
+			wantErr:   true,
+			targetErr: ErrNotificationConfigNotFound,
+		}, {
+			name:      "OK_Expired_Config_Reloaded",
+			config:    config,
+			wantCalls: 2,
+		}, {
+			name:      "ERROR_Backend_Errors_Not_Cached",
+			err:       errors.Join(redisErr, ErrOperatingNotificationConfig),
+			ttl:       time.Minute,
+			wantCalls: 2,
+			wantErr:   true,
+			targetErr: ErrOperatingNotificationConfig,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delegate := Mock[Service]()
+			When(delegate.GetByName("Newsletter")).ThenReturn(tt.config, tt.err)
+
+			c := NewCachedClient(delegate, Mock[redis.Cmdable](), tt.ttl)
+			for i := 0; i < 2; i++ {
+				got, err := c.GetByName("Newsletter")
+				if (err != nil) != tt.wantErr {
+					t.Errorf("GetByName() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+
+				if tt.wantErr && !errors.Is(err, tt.targetErr) {
+					t.Errorf("GetByName() error = %v, targetErr = %v", err, tt.targetErr)
+					return
+				}
+
+				if !reflect.DeepEqual(got, tt.config) {
+					t.Errorf("GetByName() got = %v, want %v", got, tt.config)
+				}
+			}
+
+			Verify(delegate, Times(tt.wantCalls)).GetByName("Newsletter")
+		})
+	}
+}
+
+func Test_cachedClient_GetByNames(t *testing.T) {
+	SetUp(t)
+
+	config := &model.Config{Name: "Marketing", LimitCount: 1, TimeAmount: 1, TimeUnit: "DAY"}
+	loaded := map[string]*model.Config{"Marketing": config}
+
+	tests := []struct {
+		name      string
+		err       error
+		ttl       time.Duration
+		want      map[string]*model.Config
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "OK_Configs_And_Not_Found_Cached",
+			ttl:       time.Minute,
+			want:      loaded,
+			wantCalls: 1,
+		}, {
+			name:      "OK_Expired_Configs_Reloaded",
+			want:      loaded,
+			wantCalls: 2,
+		}, {
+			name:      "ERROR_Backend_Errors_Not_Cached",
+			err:       ErrOperatingNotificationConfig,
+			ttl:       time.Minute,
+			wantCalls: 2,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names := []string{"Marketing", "GLOBAL"}
+			delegate := Mock[Service]()
+			WhenDouble(delegate.GetByNames(Any[[]string]())).ThenAnswer(func(args []any) (map[string]*model.Config, error) {
+				if !reflect.DeepEqual(args[0], names) {
+					t.Errorf("GetByNames() names = %v, want %v", args[0], names)
+				}
+
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return loaded, nil
+			})
+
+			c := NewCachedClient(delegate, Mock[redis.Cmdable](), tt.ttl)
+			for i := 0; i < 2; i++ {
+				got, err := c.GetByNames(names)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("GetByNames() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("GetByNames() got = %v, want %v", got, tt.want)
+				}
+			}
+
+			Verify(delegate, Times(tt.wantCalls)).GetByNames(Any[[]string]())
+		})
+	}
+}
+
+func Test_cachedClient_PersistNotificationConfig(t *testing.T) {
+	SetUp(t)
+
+	config := &model.Config{Name: "Newsletter", LimitCount: 1, TimeAmount: 1, TimeUnit: "DAY"}
+
+	tests := []struct {
+		name       string
+		persistErr error
+		publishErr error
+		wantCalls  int
+		wantErr    bool
+	}{
+		{
+			name:      "OK_Config_Invalidated",
+			wantCalls: 2,
+		}, {
+			name:       "OK_Config_Invalidated_Locally_When_Publish_Fails",
+			publishErr: redisErr,
+			wantCalls:  2,
+		}, {
+			name:       "ERROR_Persisting_Config",
+			persistErr: ErrOperatingNotificationConfig,
+			wantCalls:  1,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delegate := Mock[Service]()
+			When(delegate.GetByName("Newsletter")).ThenReturn(config, nil)
+			When(delegate.PersistNotificationConfig(config)).ThenReturn(tt.persistErr)
+
+			rdbMock := Mock[redis.Cmdable]()
+			if tt.persistErr == nil {
+				When(rdbMock.Publish(model.ConfigInvalidationChannel, "Newsletter")).
+					ThenReturn(redis.NewIntResult(1, tt.publishErr))
+			}
+
+			c := NewCachedClient(delegate, rdbMock, time.Minute)
+			if _, err := c.GetByName("Newsletter"); err != nil {
+				t.Fatalf("GetByName() error = %v", err)
+			}
+
+			if err := c.PersistNotificationConfig(config); (err != nil) != tt.wantErr {
+				t.Errorf("PersistNotificationConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if _, err := c.GetByName("Newsletter"); err != nil {
+				t.Fatalf("GetByName() error = %v", err)
+			}
+
+			Verify(delegate, Times(tt.wantCalls)).GetByName("Newsletter")
+		})
+	}
+}
+
+func Test_cachedClient_GetOverride(t *testing.T) {
+	SetUp(t)
+
+	allTypes := &model.Override{Recipient: "a@a.a", LimitCount: 5, TimeAmount: 1, TimeUnit: "DAY"}
+	news := &model.Override{Recipient: "a@a.a", NotificationType: "News", Exempt: true}
+
+	tests := []struct {
+		name      string
+		overrides []*model.Override
+		err       error
+		ttl       time.Duration
+		args      string
+		want      *model.Override
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "OK_Type_Override_Cached",
+			overrides: []*model.Override{allTypes, news},
+			ttl:       time.Minute,
+			args:      "News",
+			want:      news,
+			wantCalls: 1,
+		}, {
+			name:      "OK_Recipient_Override_Cached",
+			overrides: []*model.Override{allTypes, news},
+			ttl:       time.Minute,
+			args:      "Status",
+			want:      allTypes,
+			wantCalls: 1,
+		}, {
+			name:      "OK_No_Override_Cached",
+			overrides: []*model.Override{},
+			ttl:       time.Minute,
+			args:      "News",
+			wantCalls: 1,
+		}, {
+			name:      "OK_Expired_Overrides_Reloaded",
+			overrides: []*model.Override{news},
+			args:      "News",
+			want:      news,
+			wantCalls: 2,
+		}, {
+			name:      "ERROR_Backend_Errors_Not_Cached",
+			err:       ErrOperatingRecipientOverride,
+			ttl:       time.Minute,
+			args:      "News",
+			wantCalls: 2,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delegate := Mock[Service]()
+			When(delegate.ListRecipientOverrides("a@a.a")).ThenReturn(tt.overrides, tt.err)
+
+			c := NewCachedClient(delegate, Mock[redis.Cmdable](), tt.ttl)
+			for i := 0; i < 2; i++ {
+				got, err := c.GetOverride("a@a.a", tt.args)
+				if (err != nil) != tt.wantErr {
+					t.Errorf("GetOverride() error = %v, wantErr %v", err, tt.wantErr)
+					return
+				}
+
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("GetOverride() got = %v, want %v", got, tt.want)
+				}
+			}
+
+			Verify(delegate, Times(tt.wantCalls)).ListRecipientOverrides("a@a.a")
+		})
+	}
+}
+
+func Test_cachedClient_PersistRecipientOverride(t *testing.T) {
+	SetUp(t)
+
+	tests := []struct {
+		name       string
+		persistErr error
+		publishErr error
+		wantCalls  int
+		wantErr    bool
+	}{
+		{
+			name:      "OK_Overrides_Invalidated",
+			wantCalls: 2,
+		}, {
+			name:       "OK_Overrides_Invalidated_Locally_When_Publish_Fails",
+			publishErr: redisErr,
+			wantCalls:  2,
+		}, {
+			name:       "ERROR_Persisting_Override",
+			persistErr: ErrOperatingRecipientOverride,
+			wantCalls:  1,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delegate := Mock[Service]()
+			When(delegate.ListRecipientOverrides("a@a.a")).ThenReturn([]*model.Override{override}, nil)
+			When(delegate.PersistRecipientOverride(override)).ThenReturn(tt.persistErr)
+
+			rdbMock := Mock[redis.Cmdable]()
+			if tt.persistErr == nil {
+				When(rdbMock.Publish(model.OverrideInvalidationChannel, "a@a.a")).
+					ThenReturn(redis.NewIntResult(1, tt.publishErr))
+			}
+
+			c := NewCachedClient(delegate, rdbMock, time.Minute)
+			if _, err := c.GetOverride("a@a.a", "News"); err != nil {
+				t.Fatalf("GetOverride() error = %v", err)
+			}
+
+			if err := c.PersistRecipientOverride(override); (err != nil) != tt.wantErr {
+				t.Errorf("PersistRecipientOverride() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if _, err := c.GetOverride("a@a.a", "News"); err != nil {
+				t.Fatalf("GetOverride() error = %v", err)
+			}
+
+			Verify(delegate, Times(tt.wantCalls)).ListRecipientOverrides("a@a.a")
+		})
+	}
+}
+
+func Test_cachedClient_Listen(t *testing.T) {
+	SetUp(t)
+
+	delegate := Mock[Service]()
+	When(delegate.GetByName("Newsletter")).ThenReturn(&model.Config{Name: "Newsletter"}, nil)
+	When(delegate.ListRecipientOverrides("a@a.a")).ThenReturn([]*model.Override{override}, nil)
+
+	c := NewCachedClient(delegate, Mock[redis.Cmdable](), time.Minute)
+	if _, err := c.GetByName("Newsletter"); err != nil {
+		t.Fatalf("GetByName() error = %v", err)
+	}
+
+	if _, err := c.GetOverride("a@a.a", "Newsletter"); err != nil {
+		t.Fatalf("GetOverride() error = %v", err)
+	}
+
+	messages := make(chan *redis.Message, 2)
+	messages <- &redis.Message{Channel: model.ConfigInvalidationChannel, Payload: "Newsletter"}
+	messages <- &redis.Message{Channel: model.OverrideInvalidationChannel, Payload: "a@a.a"}
+	close(messages)
+	c.Listen(context.Background(), messages)
+
+	if _, err := c.GetByName("Newsletter"); err != nil {
+		t.Fatalf("GetByName() error = %v", err)
+	}
+
+	if _, err := c.GetOverride("a@a.a", "Newsletter"); err != nil {
+		t.Fatalf("GetOverride() error = %v", err)
+	}
+
+	Verify(delegate, Times(2)).GetByName("Newsletter")
+	Verify(delegate, Times(2)).ListRecipientOverrides("a@a.a")
+}
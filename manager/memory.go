@@ -0,0 +1,222 @@
+package manager
+
+import (
+	"errors"
+	. "github.com/sebasir/rate-limiter-example/app_errors"
+	"github.com/sebasir/rate-limiter-example/model"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+)
+
+// memoryClient keeps the configs and overrides serialized in memory, as the Redis client does, so
+// callers never share their state with the store.
+type memoryClient struct {
+	mu        sync.RWMutex
+	configs   map[string]string
+	overrides map[string]map[string]string
+	shadow    map[string]int64
+	logger    *zap.Logger
+}
+
+// NewInMemoryClient builds a Service keeping its state in the process memory, for single-instance
+// deployments running without Redis. The state is lost on restart.
+func NewInMemoryClient() Service {
+	return &memoryClient{
+		configs:   make(map[string]string),
+		overrides: make(map[string]map[string]string),
+		shadow:    make(map[string]int64),
+		logger:    zap.L(),
+	}
+}
+
+func (c *memoryClient) ListNotificationConfig() ([]*model.Config, error) {
+	c.logger.Debug("retrieving notification config list")
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	configs := make([]*model.Config, 0, len(c.configs))
+	for name, raw := range c.configs {
+		config := &model.Config{}
+		if err := config.FromJSONString(raw); err != nil {
+			return nil, LogAndError("error parsing notification config from memory",
+				errors.Join(err, ErrOperatingNotificationConfig), c.logger, zap.String("name", name))
+		}
+		configs = append(configs, config)
+	}
+
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].Name < configs[j].Name
+	})
+
+	return configs, nil
+}
+
+func (c *memoryClient) GetByName(name string) (*model.Config, error) {
+	nameField := zap.String("name", name)
+	c.logger.Debug("retrieving notification config from name", nameField)
+
+	c.mu.RLock()
+	raw, exists := c.configs[name]
+	c.mu.RUnlock()
+	if !exists {
+		c.logger.Debug("notification config not found", nameField)
+		return nil, errors.Join(ErrNotificationConfigNotFound, ErrOperatingNotificationConfig)
+	}
+
+	config := &model.Config{}
+	if err := config.FromJSONString(raw); err != nil {
+		return nil, LogAndError("error parsing notification config from memory",
+			errors.Join(err, ErrOperatingNotificationConfig), c.logger, nameField)
+	}
+
+	return config, nil
+}
+
+func (c *memoryClient) GetByNames(names []string) (map[string]*model.Config, error) {
+	configs := make(map[string]*model.Config, len(names))
+	for _, name := range names {
+		config, err := c.GetByName(name)
+		if errors.Is(err, ErrNotificationConfigNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		configs[name] = config
+	}
+
+	return configs, nil
+}
+
+func (c *memoryClient) PersistNotificationConfig(config *model.Config) error {
+	configField := zap.String("name", config.Name)
+	c.logger.Debug("persisting notification config", configField)
+
+	jsonStr, err := config.AsJSONString()
+	if err != nil {
+		return LogAndError("error marshalling notification config",
+			errors.Join(err, ErrOperatingNotificationConfig), c.logger, configField)
+	}
+
+	c.mu.Lock()
+	c.configs[config.Name] = jsonStr
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *memoryClient) ListShadowRejections() (map[string]int64, error) {
+	c.logger.Debug("retrieving shadow rejection counts")
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	counts := make(map[string]int64, len(c.shadow))
+	for name, count := range c.shadow {
+		counts[name] = count
+	}
+
+	return counts, nil
+}
+
+func (c *memoryClient) IncrementShadowRejections(name string) error {
+	c.logger.Debug("incrementing shadow rejection count", zap.String("name", name))
+
+	c.mu.Lock()
+	c.shadow[name]++
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *memoryClient) ListRecipientOverrides(recipient string) ([]*model.Override, error) {
+	recipientField := zap.String("recipient", recipient)
+	c.logger.Debug("retrieving recipient override list", recipientField)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	values := c.overrides[recipient]
+	scopes := make([]string, 0, len(values))
+	for scope := range values {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	overrides := make([]*model.Override, len(scopes))
+	for i, scope := range scopes {
+		overrides[i] = &model.Override{}
+		if err := overrides[i].FromJSONString(values[scope]); err != nil {
+			return nil, LogAndError("error parsing recipient override from memory",
+				errors.Join(err, ErrOperatingRecipientOverride), c.logger, recipientField, zap.String("scope", scope))
+		}
+	}
+
+	return overrides, nil
+}
+
+func (c *memoryClient) GetOverride(recipient, notificationType string) (*model.Override, error) {
+	recipientField := zap.String("recipient", recipient)
+	c.logger.Debug("retrieving recipient override", recipientField, zap.String("notification_type", notificationType))
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, scope := range []string{notificationType, model.AllNotificationTypes} {
+		raw, exists := c.overrides[recipient][scope]
+		if !exists {
+			continue
+		}
+
+		override := &model.Override{}
+		if err := override.FromJSONString(raw); err != nil {
+			return nil, LogAndError("error parsing recipient override from memory",
+				errors.Join(err, ErrOperatingRecipientOverride), c.logger, recipientField)
+		}
+
+		return override, nil
+	}
+
+	return nil, nil
+}
+
+func (c *memoryClient) PersistRecipientOverride(override *model.Override) error {
+	recipientField := zap.String("recipient", override.Recipient)
+	scopeField := zap.String("scope", override.Scope())
+	c.logger.Debug("persisting recipient override", recipientField, scopeField)
+
+	jsonStr, err := override.AsJSONString()
+	if err != nil {
+		return LogAndError("error marshalling recipient override",
+			errors.Join(err, ErrOperatingRecipientOverride), c.logger, recipientField, scopeField)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.overrides[override.Recipient] == nil {
+		c.overrides[override.Recipient] = make(map[string]string)
+	}
+	c.overrides[override.Recipient][override.Scope()] = jsonStr
+	return nil
+}
+
+func (c *memoryClient) DeleteRecipientOverride(recipient, notificationType string) error {
+	scope := (&model.Override{NotificationType: notificationType}).Scope()
+	c.logger.Debug("deleting recipient override", zap.String("recipient", recipient), zap.String("scope", scope))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.overrides[recipient][scope]; !exists {
+		return ErrRecipientOverrideNotFound
+	}
+
+	delete(c.overrides[recipient], scope)
+	if len(c.overrides[recipient]) == 0 {
+		delete(c.overrides, recipient)
+	}
+
+	return nil
+}
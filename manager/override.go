@@ -0,0 +1,112 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/sebasir/rate-limiter-example/app_errors"
+	"github.com/sebasir/rate-limiter-example/model"
+	"go.uber.org/zap"
+	"sort"
+)
+
+var ErrOperatingRecipientOverride = errors.New("error operating recipient override")
+
+var ErrRecipientOverrideNotFound = errors.New("recipient override not found")
+
+func (c *client) ListRecipientOverrides(recipient string) ([]*model.Override, error) {
+	keyField := zap.String("key", overrideKey(recipient))
+	c.logger.Debug("retrieving recipient override list", keyField)
+
+	values, err := c.rdb.HGetAll(overrideKey(recipient)).Result()
+	if err != nil {
+		return nil, LogAndError("error retrieving recipient override list",
+			errors.Join(err, ErrOperatingRecipientOverride), c.logger, keyField)
+	}
+
+	scopes := make([]string, 0, len(values))
+	for scope := range values {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	overrides := make([]*model.Override, len(scopes))
+	for i, scope := range scopes {
+		overrides[i] = &model.Override{}
+		if err := overrides[i].FromJSONString(values[scope]); err != nil {
+			return nil, LogAndError("error parsing recipient override from DB",
+				errors.Join(err, ErrOperatingRecipientOverride), c.logger, keyField, zap.String("scope", scope))
+		}
+	}
+
+	return overrides, nil
+}
+
+func (c *client) GetOverride(recipient, notificationType string) (*model.Override, error) {
+	keyField := zap.String("key", overrideKey(recipient))
+	c.logger.Debug("retrieving recipient override", keyField, zap.String("notification_type", notificationType))
+
+	values, err := c.rdb.HMGet(overrideKey(recipient), notificationType, model.AllNotificationTypes).Result()
+	if err != nil {
+		return nil, LogAndError("error retrieving recipient override",
+			errors.Join(err, ErrOperatingRecipientOverride), c.logger, keyField)
+	}
+
+	for _, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		override := &model.Override{}
+		if err := override.FromJSONString(raw); err != nil {
+			return nil, LogAndError("error parsing recipient override from DB",
+				errors.Join(err, ErrOperatingRecipientOverride), c.logger, keyField)
+		}
+
+		return override, nil
+	}
+
+	return nil, nil
+}
+
+func (c *client) PersistRecipientOverride(override *model.Override) error {
+	keyField := zap.String("key", overrideKey(override.Recipient))
+	scopeField := zap.String("scope", override.Scope())
+	c.logger.Debug("persisting recipient override", keyField, scopeField)
+
+	jsonStr, err := override.AsJSONString()
+	if err != nil {
+		return LogAndError("error marshalling recipient override",
+			errors.Join(err, ErrOperatingRecipientOverride), c.logger, keyField, scopeField)
+	}
+
+	if err := c.rdb.HSet(overrideKey(override.Recipient), override.Scope(), jsonStr).Err(); err != nil {
+		return LogAndError("error persisting recipient override",
+			errors.Join(err, ErrOperatingRecipientOverride), c.logger, keyField, scopeField)
+	}
+
+	return nil
+}
+
+func (c *client) DeleteRecipientOverride(recipient, notificationType string) error {
+	scope := (&model.Override{NotificationType: notificationType}).Scope()
+	keyField := zap.String("key", overrideKey(recipient))
+	scopeField := zap.String("scope", scope)
+	c.logger.Debug("deleting recipient override", keyField, scopeField)
+
+	deleted, err := c.rdb.HDel(overrideKey(recipient), scope).Result()
+	if err != nil {
+		return LogAndError("error deleting recipient override",
+			errors.Join(err, ErrOperatingRecipientOverride), c.logger, keyField, scopeField)
+	}
+
+	if deleted == 0 {
+		return ErrRecipientOverrideNotFound
+	}
+
+	return nil
+}
+
+func overrideKey(recipient string) string {
+	return fmt.Sprintf("%s:%s", model.RecipientOverrideSet, recipient)
+}
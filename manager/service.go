@@ -7,5 +7,13 @@ import (
 
 type Service interface {
 	service.ConfigClient
+	service.OverrideClient
 	GetByName(name string) (*model.Config, error)
+	// GetByNames returns the configs found among the names, keyed by name, fetching all of them at
+	// once.
+	GetByNames(names []string) (map[string]*model.Config, error)
+	IncrementShadowRejections(name string) error
+	// GetOverride returns the override of the recipient for the notification type, falling back
+	// to the one for all of its types, or nil when there is none.
+	GetOverride(recipient, notificationType string) (*model.Override, error)
 }
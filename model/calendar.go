@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// CalendarWindow returns the start and end of the calendar window of amount time units containing
+// t in loc. Windows shorter than a day are counted from midnight, never spanning it. Longer ones
+// are counted from the Unix epoch: DAY windows from its date, WEEK windows from the Monday of its
+// week, and MONTH windows from its month, lasting as long as the months they span.
+func CalendarWindow(t time.Time, loc *time.Location, unit string, amount int64) (time.Time, time.Time) {
+	local := t.In(loc)
+	year, month, day := local.Date()
+	switch unit {
+	case "MONTH":
+		months := int64(year-1970)*12 + int64(month-1)
+		offset := int((months%amount + amount) % amount)
+		return time.Date(year, month-time.Month(offset), 1, 0, 0, 0, 0, loc),
+			time.Date(year, month-time.Month(offset)+time.Month(amount), 1, 0, 0, 0, 0, loc)
+	case "DAY", "WEEK":
+		length := amount
+		// the epoch date was a Thursday, 3 days after the Monday its week started on
+		days := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400
+		if unit == "WEEK" {
+			length, days = amount*7, days+3
+		}
+
+		offset := int((days%length + length) % length)
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, loc),
+			time.Date(year, month, day-offset+int(length), 0, 0, 0, 0, loc)
+	}
+
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	window := TimeUnitMap[unit] * time.Duration(amount)
+	start := midnight.Add(local.Sub(midnight) / window * window)
+	end := start.Add(window)
+	if nextMidnight := time.Date(year, month, day+1, 0, 0, 0, 0, loc); end.After(nextMidnight) {
+		end = nextMidnight
+	}
+
+	return start, end
+}
@@ -2,25 +2,140 @@ package model
 
 import (
 	"encoding/json"
+	"sync"
 	"time"
 )
 
 const NotificationConfigSet = "NOTIFICATION_CONFIG"
 
+// ConfigInvalidationChannel is the Redis channel announcing the name of every config persisted.
+const ConfigInvalidationChannel = "NOTIFICATION_CONFIG_INVALIDATION"
+
+const ShadowRejectionSet = "SHADOW_REJECTIONS"
+
+const DeferredNotificationSet = "DEFERRED_NOTIFICATIONS"
+
+// DeduplicationPrefix prefixes the keys of the fingerprints of the notifications recently sent.
+const DeduplicationPrefix = "DEDUPLICATION"
+
+const (
+	FixedWindow      = "FIXED_WINDOW"
+	SlidingWindowLog = "SLIDING_WINDOW_LOG"
+	TokenBucket      = "TOKEN_BUCKET"
+	GCRA             = "GCRA"
+)
+
+var Algorithms = []string{FixedWindow, SlidingWindowLog, TokenBucket, GCRA}
+
+const (
+	CountAll      = "ALL"
+	CountAccepted = "ACCEPTED"
+)
+
+var CountingPolicies = []string{CountAll, CountAccepted}
+
+const (
+	Enforce  = "ENFORCE"
+	Shadow   = "SHADOW"
+	Disabled = "DISABLED"
+)
+
+var Modes = []string{Enforce, Shadow, Disabled}
+
+const (
+	OverflowReject = "REJECT"
+	OverflowDefer  = "DEFER"
+)
+
+var OverflowPolicies = []string{OverflowReject, OverflowDefer}
+
+const (
+	FailOpen   = "FAIL_OPEN"
+	FailClosed = "FAIL_CLOSED"
+	Fallback   = "FALLBACK"
+)
+
+var DegradationPolicies = []string{FailOpen, FailClosed, Fallback}
+
+const (
+	AlignRolling  = "ROLLING"
+	AlignCalendar = "CALENDAR"
+)
+
+var Alignments = []string{AlignRolling, AlignCalendar}
+
+// GlobalGroup is the name of the config capping every notification type of a recipient together.
+const GlobalGroup = "GLOBAL"
+
+// TimeUnits lists the keys of TimeUnitMap from the shortest unit to the longest one.
+var TimeUnits = []string{"SECOND", "MINUTE", "HOUR", "DAY", "WEEK", "MONTH"}
+
 var (
+	// TimeUnitMap holds the length of every time unit. Rolling MONTH windows last 30 days, while
+	// calendar-aligned ones follow the length of every month.
 	TimeUnitMap = map[string]time.Duration{
 		"SECOND": time.Second,
 		"MINUTE": time.Minute,
 		"HOUR":   time.Hour,
 		"DAY":    time.Hour * time.Duration(24),
+		"WEEK":   time.Hour * time.Duration(24*7),
+		"MONTH":  time.Hour * time.Duration(24*30),
 	}
 )
 
 type Config struct {
 	Name       string `json:"name" validate:"required"`
 	LimitCount int64  `json:"limitCount" validate:"gte=1"`
-	TimeAmount int64  `json:"timeAmount" validate:"gte=1"`
-	TimeUnit   string `json:"timeUnit" validate:"time-unit"`
+	TimeAmount int64  `json:"timeAmount,omitempty" validate:"time-amount"`
+	TimeUnit   string `json:"timeUnit,omitempty" validate:"time-unit"`
+	// Window is an ISO-8601 duration (e.g. PT90M, P1D or P1M) replacing TimeAmount and TimeUnit.
+	Window    string `json:"window,omitempty" validate:"window"`
+	Algorithm string `json:"algorithm,omitempty" validate:"algorithm"`
+	// Mode tells whether the limits are enforced (ENFORCE, the default), only evaluated to count the
+	// would-be rejections while delivering every notification (SHADOW), or skipped (DISABLED).
+	Mode string `json:"mode,omitempty" validate:"mode"`
+	// CountingPolicy tells whether every attempt consumes a unit (ALL), even when rejected, or only
+	// the accepted ones do (ACCEPTED, the default).
+	CountingPolicy string `json:"countingPolicy,omitempty" validate:"counting-policy"`
+	// OverflowPolicy tells whether the notifications exceeding the limits are rejected (REJECT, the
+	// default) or queued to be delivered once the window reopens (DEFER).
+	OverflowPolicy string `json:"overflowPolicy,omitempty" validate:"overflow-policy"`
+	// DegradationPolicy tells how the notifications are handled while Redis is unavailable: sent
+	// without limits (FAIL_OPEN), rejected (FAIL_CLOSED), or limited by an in-memory limiter local to
+	// the instance (FALLBACK). The app default applies when empty.
+	DegradationPolicy string `json:"degradationPolicy,omitempty" validate:"degradation-policy"`
+	// Alignment tells whether the windows start on the first unit consumed (ROLLING, the default) or
+	// follow the calendar of TimeZone (CALENDAR): DAY windows reset at midnight, HOUR windows on the
+	// hour, and so on. Algorithms other than FIXED_WINDOW start over on every calendar window too.
+	Alignment string `json:"alignment,omitempty" validate:"alignment"`
+	// TimeZone is the IANA time zone of the CALENDAR windows and quiet hours, UTC by default.
+	TimeZone string `json:"timeZone,omitempty" validate:"time-zone"`
+	// QuietHours are the periods the notifications are held back in, regardless of the limits.
+	QuietHours []QuietHours `json:"quietHours,omitempty" validate:"dive"`
+	// DedupWindow is the ISO-8601 duration (e.g. PT10M) an identical notification to the same
+	// recipient is suppressed for after the first one. Notifications are not deduplicated when empty.
+	DedupWindow string `json:"dedupWindow,omitempty" validate:"duration"`
+	// BurstCapacity and RefillRate only apply to TOKEN_BUCKET: the bucket holds up to BurstCapacity
+	// tokens and gets RefillRate tokens back on every CalculateTime window. GCRA also honors
+	// BurstCapacity as the amount of sends allowed back to back, spacing them evenly otherwise.
+	BurstCapacity int64 `json:"burstCapacity,omitempty" validate:"required_if=Algorithm TOKEN_BUCKET,gte=0"`
+	RefillRate    int64 `json:"refillRate,omitempty" validate:"required_if=Algorithm TOKEN_BUCKET,gte=0"`
+	// Limits are enforced on top of the main limit, each as a plain LimitCount per window cap.
+	Limits []Limit `json:"limits,omitempty" validate:"dive"`
+	// DomainLimits cap the units of the type shared by every recipient of the same email domain.
+	DomainLimits []Limit `json:"domainLimits,omitempty" validate:"dive"`
+	// RefundOnFailure gives the unit back to the limits when the notification could not be delivered.
+	RefundOnFailure bool `json:"refundOnFailure,omitempty"`
+	// Group names the config capping this type together with the rest of the types in the group,
+	// enforced along with the GLOBAL config, if any. Caps use their windows as plain LimitCount caps.
+	Group string `json:"group,omitempty"`
+}
+
+type Limit struct {
+	LimitCount int64  `json:"limitCount" validate:"gte=1"`
+	TimeAmount int64  `json:"timeAmount,omitempty" validate:"time-amount"`
+	TimeUnit   string `json:"timeUnit,omitempty" validate:"time-unit"`
+	Window     string `json:"window,omitempty" validate:"window"`
 }
 
 func (c *Config) AsJSONString() (string, error) {
@@ -41,5 +156,129 @@ func (c *Config) FromJSONString(raw string) error {
 }
 
 func (c *Config) CalculateTime() time.Duration {
-	return TimeUnitMap[c.TimeUnit] * time.Duration(c.TimeAmount)
+	amount, unit := c.ResolveWindow()
+	return TimeUnitMap[unit] * time.Duration(amount)
+}
+
+func (l *Limit) CalculateTime() time.Duration {
+	amount, unit := l.ResolveWindow()
+	return TimeUnitMap[unit] * time.Duration(amount)
+}
+
+// ResolveWindow returns the amount of time units of the window, parsed out of Window when set.
+func (c *Config) ResolveWindow() (int64, string) {
+	return resolveWindow(c.Window, c.TimeAmount, c.TimeUnit)
+}
+
+func (l *Limit) ResolveWindow() (int64, string) {
+	return resolveWindow(l.Window, l.TimeAmount, l.TimeUnit)
+}
+
+func resolveWindow(window string, amount int64, unit string) (int64, string) {
+	if window != "" {
+		if amount, unit, err := ParseISODuration(window); err == nil {
+			return amount, unit
+		}
+	}
+
+	return amount, unit
+}
+
+func (c *Config) ResolveAlgorithm() string {
+	if c.Algorithm == "" {
+		return FixedWindow
+	}
+
+	return c.Algorithm
+}
+
+func (c *Config) ResolveMode() string {
+	if c.Mode == "" {
+		return Enforce
+	}
+
+	return c.Mode
+}
+
+func (c *Config) ResolveCountingPolicy() string {
+	if c.CountingPolicy == "" {
+		return CountAccepted
+	}
+
+	return c.CountingPolicy
+}
+
+func (c *Config) ResolveOverflowPolicy() string {
+	if c.OverflowPolicy == "" {
+		return OverflowReject
+	}
+
+	return c.OverflowPolicy
+}
+
+// DedupTTL returns how long the fingerprint of a notification is kept, or 0 when not deduplicated.
+func (c *Config) DedupTTL() time.Duration {
+	if c.DedupWindow == "" {
+		return 0
+	}
+
+	amount, unit, err := ParseISODuration(c.DedupWindow)
+	if err != nil {
+		return 0
+	}
+
+	return TimeUnitMap[unit] * time.Duration(amount)
+}
+
+// QuietHoursAt returns the quiet hours t is within, along with the end of their period, or nil when
+// t is out of every one. The period ending the latest wins on overlaps.
+func (c *Config) QuietHoursAt(t time.Time) (*QuietHours, time.Time) {
+	var quiet *QuietHours
+	var until time.Time
+	for i := range c.QuietHours {
+		loc := c.ResolveLocation()
+		if c.QuietHours[i].TimeZone != "" {
+			if zone := loadLocation(c.QuietHours[i].TimeZone); zone != nil {
+				loc = zone
+			}
+		}
+
+		if end := c.QuietHours[i].Until(t, loc); end.After(until) {
+			quiet, until = &c.QuietHours[i], end
+		}
+	}
+
+	return quiet, until
+}
+
+func (c *Config) ResolveAlignment() string {
+	if c.Alignment == "" {
+		return AlignRolling
+	}
+
+	return c.Alignment
+}
+
+// ResolveLocation returns the location of the TimeZone, or UTC when empty or unknown.
+func (c *Config) ResolveLocation() *time.Location {
+	if loc := loadLocation(c.TimeZone); loc != nil {
+		return loc
+	}
+
+	return time.UTC
+}
+
+// locations caches the locations loaded by name, nil when unknown, as loading them reads the time
+// zone database.
+var locations sync.Map
+
+func loadLocation(name string) *time.Location {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+
+	// the location is nil when unknown
+	loc, _ := time.LoadLocation(name)
+	locations.Store(name, loc)
+	return loc
 }
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DeferredNotification is a notification waiting in the delay queue for the window of its recipient
+// to reopen. ID tells apart identical notifications deferred more than once, while Attempts counts
+// the deliveries of the notification that failed, backing off its retries.
+type DeferredNotification struct {
+	ID               string    `json:"id"`
+	Recipient        string    `json:"recipient"`
+	Message          string    `json:"message"`
+	NotificationType string    `json:"notificationType"`
+	ScheduledAt      time.Time `json:"scheduledAt"`
+	Attempts         int       `json:"attempts,omitempty"`
+}
+
+func (d *DeferredNotification) AsJSONString() (string, error) {
+	str, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+
+	return string(str), nil
+}
+
+func (d *DeferredNotification) FromJSONString(raw string) error {
+	if err := json.Unmarshal([]byte(raw), d); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// maxWindow is the longest window a time.Duration holds.
+const maxWindow = time.Duration(math.MaxInt64)
+
+// isoDurationUnits are the time units of the components of isoDurationPattern, in order.
+var isoDurationUnits = []string{"YEAR", "MONTH", "WEEK", "DAY", "HOUR", "MINUTE", "SECOND"}
+
+// ParseISODuration parses an ISO-8601 duration (e.g. PT90M, P1DT12H, P2W or P1Y) into an amount
+// of its shortest time unit, since windows count whole time units: PT1H30M is 90 MINUTE, and a
+// year is 12 MONTH. Fractions, and months mixed with shorter components, are not supported, as
+// months have no fixed length.
+func ParseISODuration(raw string) (int64, string, error) {
+	matches := isoDurationPattern.FindStringSubmatch(raw)
+	if matches == nil || raw == "P" || raw[len(raw)-1] == 'T' {
+		return 0, "", errors.New("invalid ISO-8601 duration")
+	}
+
+	var months int64
+	var length time.Duration
+	unit := ""
+	for i, match := range matches[1:] {
+		if match == "" {
+			continue
+		}
+
+		value, err := strconv.ParseInt(match, 10, 64)
+		if err != nil {
+			return 0, "", err
+		}
+
+		component := isoDurationUnits[i]
+		if component == "YEAR" {
+			component, value = "MONTH", min(value, math.MaxInt64/12)*12
+		}
+
+		if component != "MONTH" && months > 0 {
+			return 0, "", errors.New("ISO-8601 duration mixes months with shorter components")
+		}
+
+		unit = component
+		if value > int64(maxWindow-length)/int64(TimeUnitMap[unit])-months {
+			return 0, "", errors.New("ISO-8601 duration is too long")
+		}
+
+		if unit == "MONTH" {
+			months += value
+		} else {
+			length += TimeUnitMap[unit] * time.Duration(value)
+		}
+	}
+
+	amount := months
+	if unit != "MONTH" {
+		amount = int64(length / TimeUnitMap[unit])
+	}
+
+	if amount < 1 {
+		return 0, "", errors.New("ISO-8601 duration must not be empty")
+	}
+
+	return amount, unit, nil
+}
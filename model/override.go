@@ -0,0 +1,75 @@
+package model
+
+import "encoding/json"
+
+const RecipientOverrideSet = "RECIPIENT_OVERRIDE"
+
+// OverrideInvalidationChannel is the Redis channel announcing the recipient of every override
+// persisted or deleted.
+const OverrideInvalidationChannel = "RECIPIENT_OVERRIDE_INVALIDATION"
+
+// AllNotificationTypes identifies the overrides applying to every notification type of a recipient.
+const AllNotificationTypes = "*"
+
+// Override replaces the limits of a notification type for a recipient, or the limits of every type
+// when NotificationType is empty. An Exempt recipient is not rate limited at all, while still held
+// back by the quiet hours and deduplication of the type.
+type Override struct {
+	Recipient        string `json:"recipient" validate:"required,email"`
+	NotificationType string `json:"notificationType,omitempty"`
+	Exempt           bool   `json:"exempt,omitempty"`
+	LimitCount       int64  `json:"limitCount,omitempty" validate:"required_unless=Exempt true,gte=0"`
+	TimeAmount       int64  `json:"timeAmount,omitempty" validate:"required_without_all=Exempt Window,gte=0"`
+	TimeUnit         string `json:"timeUnit,omitempty" validate:"required_without_all=Exempt Window,omitempty,time-unit"`
+	// Window is an ISO-8601 duration replacing TimeAmount and TimeUnit, as the one of Config.
+	Window string `json:"window,omitempty" validate:"window"`
+	// BurstCapacity and RefillRate replace the ones of the notification type only when set.
+	BurstCapacity int64   `json:"burstCapacity,omitempty" validate:"gte=0"`
+	RefillRate    int64   `json:"refillRate,omitempty" validate:"gte=0"`
+	Limits        []Limit `json:"limits,omitempty" validate:"dive"`
+}
+
+func (o *Override) AsJSONString() (string, error) {
+	str, err := json.Marshal(o)
+	if err != nil {
+		return "", err
+	}
+
+	return string(str), nil
+}
+
+func (o *Override) FromJSONString(raw string) error {
+	if err := json.Unmarshal([]byte(raw), o); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Scope returns the notification type the override applies to, or AllNotificationTypes.
+func (o *Override) Scope() string {
+	if o.NotificationType == "" {
+		return AllNotificationTypes
+	}
+
+	return o.NotificationType
+}
+
+// Apply returns a copy of the config with its limits replaced by the ones of the override.
+func (o *Override) Apply(config *Config) *Config {
+	applied := *config
+	applied.LimitCount = o.LimitCount
+	applied.TimeAmount = o.TimeAmount
+	applied.TimeUnit = o.TimeUnit
+	applied.Window = o.Window
+	applied.Limits = o.Limits
+	if o.BurstCapacity > 0 {
+		applied.BurstCapacity = o.BurstCapacity
+	}
+
+	if o.RefillRate > 0 {
+		applied.RefillRate = o.RefillRate
+	}
+
+	return &applied
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// Weekdays are the day names of QuietHours, indexed by time.Weekday.
+var Weekdays = []string{"SUNDAY", "MONDAY", "TUESDAY", "WEDNESDAY", "THURSDAY", "FRIDAY", "SATURDAY"}
+
+// ClockLayout is the layout of the Start and End times of QuietHours.
+const ClockLayout = "15:04"
+
+// QuietHours is a daily period when the notifications of a type are held back, from Start to End
+// (wrapping past midnight when End is not after Start) on every day of Days it starts on, or on
+// every day when empty. TimeZone defaults to the one of the config.
+type QuietHours struct {
+	Days     []string `json:"days,omitempty" validate:"dive,weekday"`
+	Start    string   `json:"start" validate:"clock"`
+	End      string   `json:"end" validate:"clock"`
+	TimeZone string   `json:"timeZone,omitempty" validate:"time-zone"`
+	// Policy tells whether the notifications held back are rejected (REJECT, the default) or queued
+	// to be delivered once the quiet hours end (DEFER).
+	Policy string `json:"policy,omitempty" validate:"overflow-policy"`
+}
+
+func (q *QuietHours) ResolvePolicy() string {
+	if q.Policy == "" {
+		return OverflowReject
+	}
+
+	return q.Policy
+}
+
+// Until returns the end of the quiet period containing t in loc, or the zero time when t is out of
+// every period. Periods starting the day before are considered, as they may wrap past midnight.
+func (q *QuietHours) Until(t time.Time, loc *time.Location) time.Time {
+	start, startErr := time.Parse(ClockLayout, q.Start)
+	end, endErr := time.Parse(ClockLayout, q.End)
+	if startErr != nil || endErr != nil {
+		return time.Time{}
+	}
+
+	local := t.In(loc)
+	year, month, day := local.Date()
+	for _, offset := range []int{-1, 0} {
+		from := time.Date(year, month, day+offset, start.Hour(), start.Minute(), 0, 0, loc)
+		if len(q.Days) > 0 && !slices.Contains(q.Days, Weekdays[from.Weekday()]) {
+			continue
+		}
+
+		to := time.Date(year, month, day+offset, end.Hour(), end.Minute(), 0, 0, loc)
+		if !to.After(from) {
+			to = time.Date(year, month, day+offset+1, end.Hour(), end.Minute(), 0, 0, loc)
+		}
+
+		if !t.Before(from) && t.Before(to) {
+			return to
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Quota is the rate limit state of a recipient for a notification type, as of its decisive limit.
+// Unlimited quotas belong to exempt recipients or disabled types.
+type Quota struct {
+	Recipient        string     `json:"recipient"`
+	NotificationType string     `json:"notificationType"`
+	Unlimited        bool       `json:"unlimited,omitempty"`
+	LimitName        string     `json:"limitName,omitempty"`
+	Window           string     `json:"window,omitempty"`
+	Limit            int64      `json:"limit"`
+	Count            int64      `json:"count"`
+	Remaining        int64      `json:"remaining"`
+	ResetAt          *time.Time `json:"resetAt,omitempty"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+const (
+	StatusUp   = "UP"
+	StatusDown = "DOWN"
+)
+
+const (
+	BreakerClosed   = "CLOSED"
+	BreakerOpen     = "OPEN"
+	BreakerHalfOpen = "HALF_OPEN"
+)
+
+// Status is the state of the dependencies of the rate limiter: whether its store is reachable, and
+// the circuit breaker of the notification service, when there is one.
+type Status struct {
+	Store    string         `json:"store"`
+	Delegate *BreakerStatus `json:"delegate,omitempty"`
+}
+
+// BreakerStatus is the state of a circuit breaker. OpenedAt is only set while not CLOSED.
+type BreakerStatus struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+}
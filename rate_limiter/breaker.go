@@ -0,0 +1,161 @@
+package ratelimiter
+
+import (
+	"errors"
+	"github.com/sebasir/rate-limiter-example/model"
+	pb "github.com/sebasir/rate-limiter-example/notification/proto"
+	"github.com/sebasir/rate-limiter-example/service"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("notification service circuit breaker is open")
+
+var CircuitOpenResult = &pb.Result{
+	Status:          pb.Status_INTERNAL_ERROR,
+	ResponseMessage: "notification service is unavailable, circuit breaker is open",
+}
+
+// CircuitBreaker is a service.Client failing fast while its delegate keeps failing.
+type CircuitBreaker interface {
+	service.Client
+	Status() *model.BreakerStatus
+}
+
+type circuitBreaker struct {
+	delegate         service.Client
+	failureThreshold int
+	openTimeout      time.Duration
+	halfOpenRequests int
+	mu               sync.Mutex
+	state            string
+	failures         int
+	openedAt         time.Time
+	// trials are the requests let through while HALF_OPEN, and successes the ones that succeeded.
+	trials    int
+	successes int
+	// generation changes on every transition, so the outcome of a request sent in a previous state
+	// does not count in the current one.
+	generation uint64
+	logger     *zap.Logger
+}
+
+// NewCircuitBreaker wraps the delegate with a circuit breaker. It opens after failureThreshold
+// consecutive errors, failing every request with ErrCircuitOpen for openTimeout. It then lets
+// halfOpenRequests requests through, closing again once all of them succeed, or opening on the
+// first failure.
+func NewCircuitBreaker(delegate service.Client, failureThreshold int, openTimeout time.Duration, halfOpenRequests int) CircuitBreaker {
+	return &circuitBreaker{
+		delegate:         delegate,
+		failureThreshold: max(failureThreshold, 1),
+		openTimeout:      openTimeout,
+		halfOpenRequests: max(halfOpenRequests, 1),
+		state:            model.BreakerClosed,
+		logger:           zap.L(),
+	}
+}
+
+func (b *circuitBreaker) Send(notification *pb.Notification) (*pb.Result, error) {
+	generation, err := b.acquire()
+	if err != nil {
+		return CircuitOpenResult, err
+	}
+
+	res, err := b.delegate.Send(notification)
+	b.record(generation, err == nil)
+	return res, err
+}
+
+// acquire lets a request through, returning the generation it was sent in, or fails with
+// ErrCircuitOpen.
+func (b *circuitBreaker) acquire() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case model.BreakerOpen:
+		if now().Before(b.openedAt.Add(b.openTimeout)) {
+			return 0, ErrCircuitOpen
+		}
+
+		b.transition(model.BreakerHalfOpen)
+		fallthrough
+	case model.BreakerHalfOpen:
+		if b.trials >= b.halfOpenRequests {
+			return 0, ErrCircuitOpen
+		}
+
+		b.trials++
+	}
+
+	return b.generation, nil
+}
+
+func (b *circuitBreaker) record(generation uint64, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case model.BreakerClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.transition(model.BreakerOpen)
+		}
+	case model.BreakerHalfOpen:
+		if !success {
+			b.transition(model.BreakerOpen)
+			return
+		}
+
+		b.successes++
+		if b.successes >= b.halfOpenRequests {
+			b.transition(model.BreakerClosed)
+		}
+	}
+}
+
+// transition moves the breaker to state, starting over its counts. Callers hold the lock.
+func (b *circuitBreaker) transition(state string) {
+	b.logger.Info("notification service circuit breaker state changed",
+		zap.String("from", b.state), zap.String("to", state), zap.Int("consecutive_failures", b.failures))
+
+	if state == model.BreakerOpen {
+		b.openedAt = now()
+	}
+
+	if state == model.BreakerClosed {
+		b.failures = 0
+	}
+
+	b.state = state
+	b.trials = 0
+	b.successes = 0
+	b.generation++
+}
+
+func (b *circuitBreaker) Status() *model.BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := &model.BreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+	}
+
+	if b.state != model.BreakerClosed {
+		openedAt := b.openedAt.UTC()
+		status.OpenedAt = &openedAt
+	}
+
+	return status
+}
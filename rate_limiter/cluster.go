@@ -0,0 +1,168 @@
+package ratelimiter
+
+import (
+	"strings"
+)
+
+// hashTag returns the part of the key Redis Cluster hashes to pick its slot: the content of the
+// first {...} section, when not empty, or the whole key.
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+
+	return key
+}
+
+// partitionRules groups the rules sharing their hash tag, keeping them in order.
+func partitionRules(rules []Rule) [][]Rule {
+	var partitions [][]Rule
+	index := make(map[string]int)
+	for _, rule := range rules {
+		tag := hashTag(rule.Key)
+		i, exists := index[tag]
+		if !exists {
+			i = len(partitions)
+			index[tag] = i
+			partitions = append(partitions, nil)
+		}
+		partitions[i] = append(partitions[i], rule)
+	}
+
+	return partitions
+}
+
+// slotLimiter evaluates the rules of every hash tag on their own, since Redis Cluster only runs a
+// script over keys of the same slot. Allowing is then atomic within each slot only: when a slot
+// rejects the unit, the units taken from the slots evaluated before are released.
+type slotLimiter struct {
+	Limiter
+}
+
+func newSlotLimiters(limiters map[string]Limiter) map[string]Limiter {
+	for algorithm, limiter := range limiters {
+		limiters[algorithm] = &slotLimiter{
+			Limiter: limiter,
+		}
+	}
+
+	return limiters
+}
+
+func (l *slotLimiter) Allow(rules []Rule) (*Decision, error) {
+	return l.evaluate(allowOp, rules)
+}
+
+func (l *slotLimiter) Reserve(rules []Rule) (*Decision, error) {
+	return l.evaluate(reserveOp, rules)
+}
+
+func (l *slotLimiter) Peek(rules []Rule) (*Decision, error) {
+	return l.evaluate(peekOp, rules)
+}
+
+func (l *slotLimiter) evaluate(op operation, rules []Rule) (*Decision, error) {
+	partitions := partitionRules(rules)
+	if len(partitions) == 1 {
+		return l.run(op, rules)
+	}
+
+	decisions := make([]*Decision, len(partitions))
+	rejected := false
+	for i, partition := range partitions {
+		partitionOp := op
+		if rejected && op == allowOp {
+			partitionOp = peekOp
+		}
+
+		decision, err := l.run(partitionOp, partition)
+		if err != nil {
+			if op == allowOp && !rejected {
+				l.release(partitions[:i], decisions[:i])
+			}
+			return nil, err
+		}
+
+		decisions[i] = decision
+		if op == allowOp && !rejected && !decision.Allowed {
+			rejected = true
+			l.release(partitions[:i], decisions[:i])
+			if err := l.peek(partitions[:i], decisions[:i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return combineDecisions(decisions), nil
+}
+
+func (l *slotLimiter) run(op operation, rules []Rule) (*Decision, error) {
+	switch op {
+	case allowOp:
+		return l.Limiter.Allow(rules)
+	case reserveOp:
+		return l.Limiter.Reserve(rules)
+	default:
+		return l.Limiter.Peek(rules)
+	}
+}
+
+// release gives back the units of the decisions. Errors are logged by the limiter, and only leave
+// the units consumed until their window passes.
+func (l *slotLimiter) release(partitions [][]Rule, decisions []*Decision) {
+	for i, partition := range partitions {
+		_ = l.Limiter.Release(partition, decisions[i])
+	}
+}
+
+// peek replaces the decisions with the state of their partitions.
+func (l *slotLimiter) peek(partitions [][]Rule, decisions []*Decision) error {
+	for i, partition := range partitions {
+		decision, err := l.Limiter.Peek(partition)
+		if err != nil {
+			return err
+		}
+		decisions[i] = decision
+	}
+
+	return nil
+}
+
+func (l *slotLimiter) Release(rules []Rule, decision *Decision) error {
+	if decision.parts == nil {
+		return l.Limiter.Release(rules, decision)
+	}
+
+	for i, partition := range partitionRules(rules) {
+		if err := l.Limiter.Release(partition, decision.parts[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// combineDecisions merges the decisions of the partitions as the limiter scripts do with the rules:
+// the decisive one has the fewest units remaining, and the unit is available once it is in every
+// partition.
+func combineDecisions(decisions []*Decision) *Decision {
+	combined := *decisions[0]
+	for _, decision := range decisions[1:] {
+		if decision.Limit-decision.Count < combined.Limit-combined.Count {
+			combined.Rule = decision.Rule
+			combined.Limit = decision.Limit
+			combined.Count = decision.Count
+			combined.Remaining = decision.Remaining
+			combined.ResetAfter = decision.ResetAfter
+		}
+
+		combined.Allowed = combined.Allowed && decision.Allowed
+		combined.RetryAfter = max(combined.RetryAfter, decision.RetryAfter)
+	}
+
+	combined.token = ""
+	combined.parts = decisions
+	return &combined
+}
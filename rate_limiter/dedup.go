@@ -0,0 +1,84 @@
+package ratelimiter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/sebasir/rate-limiter-example/model"
+	pb "github.com/sebasir/rate-limiter-example/notification/proto"
+	"go.uber.org/zap"
+)
+
+// deduplicate claims the fingerprint of the notification for the dedup window of the config,
+// returning its key, or reporting the notification is a duplicate when already claimed. Duplicates
+// are only logged in SHADOW mode. Notifications are not deduplicated while the store is unavailable.
+func (c *client) deduplicate(n *pb.Notification, config *model.Config) (string, bool) {
+	ttl := config.DedupTTL()
+	if ttl <= 0 || !c.health.available() {
+		return "", false
+	}
+
+	recipientField := zap.String("recipient", n.Recipient)
+	key := fingerprintKey(n)
+	claimed, err := c.store.remember(key, ttl)
+	if err != nil {
+		c.health.failed(err)
+		c.logger.Warn("error trying to deduplicate notification", zap.Error(err), recipientField)
+		return "", false
+	}
+
+	if claimed {
+		return key, false
+	}
+
+	if config.ResolveMode() == model.Shadow {
+		c.logger.Info("notification would have been deduplicated", recipientField)
+		return "", false
+	}
+
+	c.logger.Debug("deduplicating notification", recipientField, zap.String("window", config.DedupWindow))
+	return "", true
+}
+
+// release gives the fingerprint back when the notification was not accepted, so a retry of it is
+// not deduplicated.
+func (c *client) release(key string, res *pb.Result) {
+	if key == "" || accepted(res) {
+		return
+	}
+
+	if err := c.store.forget(key); err != nil {
+		c.logger.Warn("error trying to release notification fingerprint", zap.Error(err))
+	}
+}
+
+// accepted reports whether the notification was sent, or queued to be sent later.
+func accepted(res *pb.Result) bool {
+	switch res.GetStatus() {
+	case pb.Status_SENT, pb.Status_QUEUED:
+		return true
+	case pb.Status_QUIET_HOURS:
+		return res.ScheduledAtMs > 0
+	}
+
+	return false
+}
+
+func deduplicatedResult(config *model.Config) *pb.Result {
+	return &pb.Result{
+		Status:          pb.Status_DEDUPLICATED,
+		ResponseMessage: fmt.Sprintf("notification to recipient was deduplicated, an identical one was sent within %s", config.DedupWindow),
+	}
+}
+
+// fingerprintKey is the key of the fingerprint of the recipient, type and message of the
+// notification, hash tagged by the recipient as its counters are.
+func fingerprintKey(n *pb.Notification) string {
+	hash := sha256.New()
+	for _, field := range []string{n.Recipient, n.NotificationType, n.Message} {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+
+	return fmt.Sprintf("%s:{%s}:%s", model.DeduplicationPrefix, n.Recipient, hex.EncodeToString(hash.Sum(nil)))
+}
@@ -0,0 +1,217 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "github.com/sebasir/rate-limiter-example/app_errors"
+	"github.com/sebasir/rate-limiter-example/model"
+	pb "github.com/sebasir/rate-limiter-example/notification/proto"
+	"github.com/sebasir/rate-limiter-example/service"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"math/rand"
+	"time"
+)
+
+var ErrDeliveringDeferredNotifications = errors.New("error delivering deferred notifications")
+
+// deferredBatchSize is the amount of due notifications taken from the delay queue on every poll.
+const deferredBatchSize = 100
+
+// deferredRetryBackoff is how long a deferred notification failing to be delivered waits for its
+// first retry, doubling on every retry up to deferredMaxRetryBackoff.
+const (
+	deferredRetryBackoff    = 5 * time.Second
+	deferredMaxRetryBackoff = 5 * time.Minute
+)
+
+// DeferredWorker delivers the notifications deferred by the DEFER overflow policy once they are due.
+type DeferredWorker interface {
+	// Run delivers the due notifications every interval until the context is done.
+	Run(ctx context.Context, interval time.Duration)
+	// DeliverDue delivers the notifications due by now, returning how many were taken from the queue.
+	DeliverDue() (int, error)
+}
+
+// redeliverer sends the deferred notifications again, skipping the checks they went through when
+// first sent.
+type redeliverer interface {
+	redeliver(n *pb.Notification) (*pb.Result, error)
+}
+
+type deferredWorker struct {
+	store  Store
+	client service.Client
+	logger *zap.Logger
+}
+
+// NewDeferredWorker builds a worker sending the due notifications through client, so they are
+// evaluated against the limits again: the ones still exceeding them are deferred once more.
+func NewDeferredWorker(store Store, client service.Client) DeferredWorker {
+	return &deferredWorker{
+		store:  store,
+		client: client,
+		logger: zap.L(),
+	}
+}
+
+func (w *deferredWorker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := w.DeliverDue(); err != nil {
+				w.logger.Warn("error trying to deliver deferred notifications", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (w *deferredWorker) DeliverDue() (int, error) {
+	members, claimErr := w.store.claimDue(now(), deferredBatchSize)
+
+	send := w.client.Send
+	if r, ok := w.client.(redeliverer); ok {
+		send = r.redeliver
+	}
+
+	var delivered int
+	var retryErr error
+	for _, member := range members {
+		deferred := &model.DeferredNotification{}
+		if err := deferred.FromJSONString(member); err != nil {
+			w.logger.Error("error trying to parse deferred notification, discarding it", zap.Error(err))
+			continue
+		}
+
+		recipientField := zap.String("recipient", deferred.Recipient)
+		res, err := send(&pb.Notification{
+			Recipient:        deferred.Recipient,
+			Message:          deferred.Message,
+			NotificationType: deferred.NotificationType,
+		})
+		// the notification was claimed out of the queue, so it goes back until delivered
+		if err != nil || proto.Equal(res, UnavailableResult) {
+			w.logger.Warn("error trying to deliver deferred notification, retrying it later", zap.Error(err),
+				recipientField, zap.String("id", deferred.ID), zap.String("status", res.GetStatus().String()))
+			if err := w.retry(deferred); err != nil {
+				retryErr = errors.Join(retryErr, LogAndError("error trying to queue deferred notification back",
+					err, w.logger, recipientField, zap.String("id", deferred.ID)))
+			}
+			continue
+		}
+
+		w.logger.Debug("deferred notification processed", recipientField, zap.String("id", deferred.ID),
+			zap.String("status", res.GetStatus().String()))
+		delivered++
+	}
+
+	if claimErr != nil {
+		return delivered, LogAndError("error trying to claim due notifications",
+			errors.Join(claimErr, retryErr, ErrDeliveringDeferredNotifications), w.logger)
+	}
+
+	if retryErr != nil {
+		return delivered, errors.Join(retryErr, ErrDeliveringDeferredNotifications)
+	}
+
+	return delivered, nil
+}
+
+// retry queues the deferred notification back, backing off exponentially on every failed delivery.
+func (w *deferredWorker) retry(deferred *model.DeferredNotification) error {
+	deferred.Attempts++
+	deferred.ScheduledAt = now().Add(min(deferredRetryBackoff<<min(deferred.Attempts-1, 16), deferredMaxRetryBackoff))
+
+	raw, err := deferred.AsJSONString()
+	if err != nil {
+		return err
+	}
+
+	return w.store.pushDeferred(raw, deferred.ScheduledAt)
+}
+
+// deferNotification queues the notification to be delivered once the decisive window of the
+// decision has room for it again, failing with errStoreUnavailable when it cannot be queued.
+func (c *client) deferNotification(n *pb.Notification, decision *Decision) (*pb.Result, error) {
+	wait := decision.RetryAfter
+	if wait <= 0 {
+		wait = decision.ResetAfter
+	}
+
+	scheduledAt := now().Add(wait)
+	if err := c.queue(n, scheduledAt); err != nil {
+		return InternalErrorResult, err
+	}
+
+	return withQuota(&pb.Result{
+		Status:          pb.Status_QUEUED,
+		ResponseMessage: fmt.Sprintf("notification to recipient was queued, %s %s window limit was reached", decision.Rule.Name, decision.Rule.Window),
+		ScheduledAtMs:   scheduledAt.UnixMilli(),
+	}, decision), nil
+}
+
+// queue pushes the notification to the delay queue, to be delivered at scheduledAt. It fails with
+// errStoreUnavailable while the store is unavailable, as deferring needs the store to queue the
+// notification.
+func (c *client) queue(n *pb.Notification, scheduledAt time.Time) error {
+	if !c.health.available() {
+		return errStoreUnavailable
+	}
+
+	recipientField := zap.String("recipient", n.Recipient)
+	deferred := &model.DeferredNotification{
+		ID:               fmt.Sprintf("%d-%d", now().UnixNano(), rand.Int63()),
+		Recipient:        n.Recipient,
+		Message:          n.Message,
+		NotificationType: n.NotificationType,
+		ScheduledAt:      scheduledAt,
+	}
+
+	raw, err := deferred.AsJSONString()
+	if err != nil {
+		return LogAndError("error trying to serialize deferred notification",
+			errors.Join(err, ErrProcessingNotificationRequest), c.logger, recipientField)
+	}
+
+	if err := c.store.pushDeferred(raw, scheduledAt); err != nil {
+		return LogAndError("error trying to queue deferred notification",
+			errors.Join(err, ErrProcessingNotificationRequest), c.logger, recipientField)
+	}
+
+	c.logger.Debug("deferring notification", recipientField, zap.String("id", deferred.ID),
+		zap.Time("scheduled_at", scheduledAt))
+	return nil
+}
+
+// holdQuietHours rejects the notification sent during quiet hours, or queues it to be delivered
+// once they end, until.
+func (c *client) holdQuietHours(n *pb.Notification, quiet *model.QuietHours, until time.Time) (*pb.Result, error) {
+	end := until.UTC().Format(time.RFC3339)
+	res := &pb.Result{
+		Status:          pb.Status_QUIET_HOURS,
+		ResponseMessage: fmt.Sprintf("notification to recipient was rejected, quiet hours end at %s", end),
+		RetryAfterMs:    until.Sub(now()).Milliseconds(),
+	}
+
+	if quiet.ResolvePolicy() == model.OverflowDefer {
+		err := c.queue(n, until)
+		if err != nil && !errors.Is(err, errStoreUnavailable) {
+			return InternalErrorResult, err
+		}
+
+		if err == nil {
+			res.ResponseMessage = fmt.Sprintf("notification to recipient was queued, quiet hours end at %s", end)
+			res.ScheduledAtMs = until.UnixMilli()
+		}
+	}
+
+	c.logger.Debug("holding notification during quiet hours", zap.String("recipient", n.Recipient),
+		zap.String("policy", quiet.ResolvePolicy()), zap.Time("until", until))
+	return res, nil
+}
@@ -0,0 +1,121 @@
+package ratelimiter
+
+import (
+	"errors"
+	"github.com/go-redis/redis"
+	. "github.com/sebasir/rate-limiter-example/app_errors"
+	"github.com/sebasir/rate-limiter-example/model"
+	"go.uber.org/zap"
+)
+
+// fixedWindowScript counts the units within every rule window in a single round trip. Unless
+// peeking, it increments the counters (only when all of them have room, unless reserving), and
+// starts the window expiration of any counter found without one, so a key can never outlive its
+// window.
+var fixedWindowScript = newScript(`
+local op = ARGV[1]
+
+local counts = {}
+local allowed = true
+for i, key in ipairs(KEYS) do
+	counts[i] = tonumber(redis.call('GET', key)) or 0
+	if counts[i] >= tonumber(ARGV[i * 2 + 1]) then
+		allowed = false
+	end
+end
+
+local decisive, fewest, retry, reset = 1, math.huge, 0, 0
+for i, key in ipairs(KEYS) do
+	local window = tonumber(ARGV[i * 2])
+	local limit = tonumber(ARGV[i * 2 + 1])
+	if op == 'reserve' or (op == 'allow' and allowed) then
+		counts[i] = redis.call('INCR', key)
+	end
+
+	local ttl = redis.call('PTTL', key)
+	if ttl == -1 then
+		redis.call('PEXPIRE', key, window)
+		ttl = window
+	end
+
+	ttl = math.max(ttl, 0)
+	if counts[i] >= limit then
+		retry = math.max(retry, ttl)
+	end
+
+	if limit - counts[i] < fewest then
+		decisive, fewest, reset = i, limit - counts[i], ttl
+	end
+end
+
+return {allowed and 1 or 0, decisive, counts[decisive], retry, reset}
+`)
+
+// fixedWindowReleaseScript decrements the counters still holding units, keeping their expiration.
+var fixedWindowReleaseScript = newScript(`
+for _, key in ipairs(KEYS) do
+	if (tonumber(redis.call('GET', key)) or 0) > 0 then
+		redis.call('DECR', key)
+	end
+end
+
+return #KEYS
+`)
+
+func init() {
+	registerLimiter(model.FixedWindow, newFixedWindowLimiter)
+}
+
+type fixedWindowLimiter struct {
+	rdb    redis.Cmdable
+	logger *zap.Logger
+}
+
+func newFixedWindowLimiter(rdb redis.Cmdable, logger *zap.Logger) Limiter {
+	return &fixedWindowLimiter{
+		rdb:    rdb,
+		logger: logger,
+	}
+}
+
+func (l *fixedWindowLimiter) Allow(rules []Rule) (*Decision, error) {
+	return l.evaluate(allowOp, rules)
+}
+
+func (l *fixedWindowLimiter) Reserve(rules []Rule) (*Decision, error) {
+	return l.evaluate(reserveOp, rules)
+}
+
+func (l *fixedWindowLimiter) Peek(rules []Rule) (*Decision, error) {
+	return l.evaluate(peekOp, rules)
+}
+
+func (l *fixedWindowLimiter) evaluate(op operation, rules []Rule) (*Decision, error) {
+	keys := ruleKeys(rules, "")
+	args := []interface{}{string(op)}
+	for _, rule := range rules {
+		args = append(args, rule.expiry(now()).Milliseconds(), rule.Limit)
+	}
+
+	decision, err := decisionFromReply(fixedWindowScript.run(l.rdb, keys, args...), rules, fixedWindowLimit)
+	if err != nil {
+		return nil, LogAndError("error trying to evaluate fixed window",
+			errors.Join(err, ErrProcessingNotificationRequest), l.logger, zap.Strings("keys", keys))
+	}
+
+	return decision, nil
+}
+
+func (l *fixedWindowLimiter) Release(rules []Rule, _ *Decision) error {
+	keys := ruleKeys(rules, "")
+	if err := fixedWindowReleaseScript.run(l.rdb, keys).Err(); err != nil {
+		return LogAndError("error trying to release fixed window",
+			errors.Join(err, ErrProcessingNotificationRequest), l.logger, zap.Strings("keys", keys))
+	}
+
+	return nil
+}
+
+func fixedWindowLimit(rule Rule) int64 {
+	return rule.Limit
+}
@@ -0,0 +1,134 @@
+package ratelimiter
+
+import (
+	"errors"
+	"github.com/go-redis/redis"
+	. "github.com/sebasir/rate-limiter-example/app_errors"
+	"github.com/sebasir/rate-limiter-example/model"
+	"go.uber.org/zap"
+)
+
+// gcraScript implements the generic cell rate algorithm over a key per rule holding the recipient
+// theoretical arrival time (TAT). Every send pushes the TAT one emission interval forward, and a
+// send is only allowed once every TAT is within its burst tolerance from now, which makes the retry
+// time the exact wait until the next allowed send.
+var gcraScript = newScript(`
+local op = ARGV[1]
+local now = tonumber(ARGV[2])
+
+local tats = {}
+local allowed = true
+for i, key in ipairs(KEYS) do
+	local tolerance = tonumber(ARGV[i * 2 + 1]) * (tonumber(ARGV[i * 2 + 2]) - 1)
+	tats[i] = math.max(tonumber(redis.call('GET', key)) or now, now)
+	if tats[i] - tolerance > now then
+		allowed = false
+	end
+end
+
+local decisive, fewest, retry, reset, count = 1, math.huge, 0, 0, 0
+for i, key in ipairs(KEYS) do
+	local interval = tonumber(ARGV[i * 2 + 1])
+	local burst = tonumber(ARGV[i * 2 + 2])
+	local tolerance = interval * (burst - 1)
+	if op ~= 'peek' and (allowed or op == 'reserve') then
+		tats[i] = tats[i] + interval
+		redis.call('SET', key, tats[i], 'PX', math.ceil(tats[i] - now))
+	end
+
+	retry = math.max(retry, math.ceil(tats[i] - tolerance - now))
+	local used = math.ceil((tats[i] - now) / interval)
+	if burst - used < fewest then
+		decisive, fewest, reset, count = i, burst - used, math.ceil(tats[i] - now), used
+	end
+end
+
+return {allowed and 1 or 0, decisive, count, retry, reset}
+`)
+
+// gcraReleaseScript pulls every TAT one emission interval back, dropping the ones left in the past.
+var gcraReleaseScript = newScript(`
+local now = tonumber(ARGV[1])
+for i, key in ipairs(KEYS) do
+	local tat = tonumber(redis.call('GET', key))
+	if tat then
+		tat = tat - tonumber(ARGV[i + 1])
+		if tat > now then
+			redis.call('SET', key, tat, 'PX', math.ceil(tat - now))
+		else
+			redis.call('DEL', key)
+		end
+	end
+end
+
+return #KEYS
+`)
+
+func init() {
+	registerLimiter(model.GCRA, newGCRALimiter)
+}
+
+type gcraLimiter struct {
+	rdb    redis.Cmdable
+	logger *zap.Logger
+}
+
+func newGCRALimiter(rdb redis.Cmdable, logger *zap.Logger) Limiter {
+	return &gcraLimiter{
+		rdb:    rdb,
+		logger: logger,
+	}
+}
+
+func (l *gcraLimiter) Allow(rules []Rule) (*Decision, error) {
+	return l.evaluate(allowOp, rules)
+}
+
+func (l *gcraLimiter) Reserve(rules []Rule) (*Decision, error) {
+	return l.evaluate(reserveOp, rules)
+}
+
+func (l *gcraLimiter) Peek(rules []Rule) (*Decision, error) {
+	return l.evaluate(peekOp, rules)
+}
+
+func (l *gcraLimiter) evaluate(op operation, rules []Rule) (*Decision, error) {
+	keys := ruleKeys(rules, model.GCRA)
+	args := []interface{}{string(op), now().UnixMilli()}
+	for _, rule := range rules {
+		args = append(args, gcraInterval(rule), gcraLimit(rule))
+	}
+
+	decision, err := decisionFromReply(gcraScript.run(l.rdb, keys, args...), rules, gcraLimit)
+	if err != nil {
+		return nil, LogAndError("error trying to evaluate GCRA",
+			errors.Join(err, ErrProcessingNotificationRequest), l.logger, zap.Strings("keys", keys))
+	}
+
+	return decision, nil
+}
+
+func (l *gcraLimiter) Release(rules []Rule, _ *Decision) error {
+	keys := ruleKeys(rules, model.GCRA)
+	args := []interface{}{now().UnixMilli()}
+	for _, rule := range rules {
+		args = append(args, gcraInterval(rule))
+	}
+
+	if err := gcraReleaseScript.run(l.rdb, keys, args...).Err(); err != nil {
+		return LogAndError("error trying to release GCRA",
+			errors.Join(err, ErrProcessingNotificationRequest), l.logger, zap.Strings("keys", keys))
+	}
+
+	return nil
+}
+
+// gcraInterval is the emission interval of the rule, in milliseconds.
+func gcraInterval(rule Rule) float64 {
+	return float64(rule.Window.Milliseconds()) / float64(rule.Limit)
+}
+
+// gcraLimit is the burst of the rule, as the amount of sends allowed back to back.
+func gcraLimit(rule Rule) int64 {
+	return max(rule.Burst, 1)
+}
@@ -5,16 +5,20 @@ import (
 	pb "github.com/sebasir/rate-limiter-example/notification/proto"
 	"github.com/sebasir/rate-limiter-example/service"
 	"go.uber.org/zap"
+	"time"
 )
 
 type grpcClient struct {
 	serviceClient pb.NotificationServiceClient
+	timeout       time.Duration
 	logger        *zap.Logger
 }
 
-func NewGRPCClient(serviceClient pb.NotificationServiceClient) service.Client {
+// NewGRPCClient builds a client of the notification service, giving up on every call after timeout.
+func NewGRPCClient(serviceClient pb.NotificationServiceClient, timeout time.Duration) service.Client {
 	return &grpcClient{
 		serviceClient: serviceClient,
+		timeout:       timeout,
 		logger:        zap.L(),
 	}
 }
@@ -24,7 +28,10 @@ func (c grpcClient) Send(notification *pb.Notification) (*pb.Result, error) {
 		Notification: notification,
 	}
 
-	response, err := c.serviceClient.Send(context.Background(), request)
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	response, err := c.serviceClient.Send(ctx, request)
 	if err != nil {
 		c.logger.Error("error sending message over gRPC client", zap.Error(err))
 		return InternalErrorResult, err
@@ -0,0 +1,110 @@
+package ratelimiter
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// healthProbeInterval is how often the store is probed while unavailable.
+const healthProbeInterval = time.Second
+
+// healthDetector tracks whether the store is available. It is marked down on the first error
+// telling the store cannot be reached, and probed in the background until it answers again.
+type healthDetector struct {
+	store    Store
+	interval time.Duration
+	mu       sync.Mutex
+	down     bool
+	probing  bool
+	probedAt time.Time
+	logger   *zap.Logger
+}
+
+func newHealthDetector(store Store) *healthDetector {
+	return &healthDetector{
+		store:    store,
+		interval: healthProbeInterval,
+		logger:   zap.L(),
+	}
+}
+
+// available reports whether the store is up. While down, it starts a probe once per interval.
+func (h *healthDetector) available() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.down && !h.probing && !now().Before(h.probedAt.Add(h.interval)) {
+		h.probing = true
+		go h.probe()
+	}
+
+	return !h.down
+}
+
+// failed marks the store down when err tells it cannot be reached, reporting whether it did.
+func (h *healthDetector) failed(err error) bool {
+	if !unavailable(err) {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.down {
+		h.logger.Warn("rate limiter store is unavailable, degrading notifications", zap.Error(err))
+		h.down = true
+		h.probedAt = now()
+	}
+
+	return true
+}
+
+func (h *healthDetector) probe() {
+	err := h.store.ping()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.probing = false
+	h.probedAt = now()
+	if err == nil {
+		h.logger.Info("rate limiter store is available again")
+		h.down = false
+	}
+}
+
+// unavailable reports whether err, or any error it wraps, comes from failing to reach Redis: a
+// network error, a closed connection or pool timeout, or a cluster not serving requests.
+func unavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	switch wrapped := err.(type) {
+	case interface{ Unwrap() error }:
+		return unavailable(wrapped.Unwrap())
+	case interface{ Unwrap() []error }:
+		for _, e := range wrapped.Unwrap() {
+			if unavailable(e) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	msg := err.Error()
+	return msg == "redis: connection pool timeout" || msg == "redis: client is closed" ||
+		strings.HasPrefix(msg, "CLUSTERDOWN ") || strings.HasPrefix(msg, "LOADING ") ||
+		strings.HasPrefix(msg, "MASTERDOWN ")
+}
@@ -0,0 +1,221 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"github.com/go-redis/redis"
+	"github.com/sebasir/rate-limiter-example/model"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Limiter implements a rate limiting algorithm, evaluating a set of rules atomically: a unit is
+// only available when every rule has one.
+type Limiter interface {
+	// Allow consumes a unit from every rule only when all of them have one available.
+	Allow(rules []Rule) (*Decision, error)
+	// Reserve consumes a unit from every rule even if some are exhausted, so the attempt counts
+	// against the limits anyway.
+	Reserve(rules []Rule) (*Decision, error)
+	// Peek evaluates the rules without consuming from them.
+	Peek(rules []Rule) (*Decision, error)
+	// Release gives the unit consumed by the decision back to every rule.
+	Release(rules []Rule, decision *Decision) error
+}
+
+// Rule is a single limit window of the config called Name, tracked on its own counter key. Limit
+// is the amount of units allowed per Window, while TOKEN_BUCKET and GCRA shape bursts with Burst
+// (and TOKEN_BUCKET refills Refill tokens per Window). End is only set on calendar windows.
+type Rule struct {
+	Name   string
+	Key    string
+	Limit  int64
+	Burst  int64
+	Refill int64
+	Window time.Duration
+	End    time.Time
+}
+
+// expiry is how long the counters of the rule last from current: its window, or until its end on
+// calendar windows.
+func (r Rule) expiry(current time.Time) time.Duration {
+	if r.End.IsZero() {
+		return r.Window
+	}
+
+	return max(r.End.Sub(current), time.Millisecond)
+}
+
+// Decision is the outcome of evaluating a Limiter, reflecting the state of the rules right after
+// the evaluation. Rule is the decisive one: the first rule rejecting the unit, or the rule with
+// the fewest units remaining when allowed.
+type Decision struct {
+	Allowed    bool
+	Rule       Rule
+	Limit      int64
+	Count      int64
+	Remaining  int64
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+	// token identifies the unit consumed, for the limiters needing it to release the unit.
+	token string
+	// parts are the decisions of every hash slot of the rules, when evaluated separately.
+	parts []*Decision
+}
+
+type limiterFactory func(rdb redis.Cmdable, logger *zap.Logger) Limiter
+
+var limiterRegistry = map[string]limiterFactory{}
+
+func registerLimiter(algorithm string, factory limiterFactory) {
+	limiterRegistry[algorithm] = factory
+}
+
+func newLimiters(rdb redis.Cmdable) map[string]Limiter {
+	limiters := make(map[string]Limiter, len(limiterRegistry))
+	for algorithm, factory := range limiterRegistry {
+		limiters[algorithm] = factory(rdb, zap.L())
+	}
+
+	return limiters
+}
+
+// newRules builds the main rule of the configuration on key, followed by a rule for each of its
+// additional limits, keyed by their position and window so limits of the same window do not share
+// their counter.
+func newRules(key string, config *model.Config) []Rule {
+	amount, unit := config.ResolveWindow()
+	rules := []Rule{alignRule(config, unit, amount, Rule{
+		Name:   config.Name,
+		Key:    key,
+		Limit:  config.LimitCount,
+		Burst:  config.BurstCapacity,
+		Refill: config.RefillRate,
+		Window: config.CalculateTime(),
+	})}
+
+	for i, limit := range config.Limits {
+		rules = append(rules, newCapRule(config, config.Name, fmt.Sprintf("%s:%d:%s", key, i+1, limit.CalculateTime()), limit))
+	}
+
+	return rules
+}
+
+// newCapRules builds the rules of a cap configuration like newRules does, except its main rule is
+// a plain cap as well, so it caps the units regardless of the algorithm evaluating it.
+func newCapRules(key string, config *model.Config) []Rule {
+	rules := []Rule{newCapRule(config, config.Name, key, model.Limit{
+		LimitCount: config.LimitCount,
+		TimeAmount: config.TimeAmount,
+		TimeUnit:   config.TimeUnit,
+		Window:     config.Window,
+	})}
+
+	return append(rules, newRules(key, config)[1:]...)
+}
+
+// newDomainRules builds a plain cap rule for each domain limit of the configuration, shared by
+// every recipient of the domain and keyed by their position and window like the additional limits.
+func newDomainRules(domain string, config *model.Config) []Rule {
+	rules := make([]Rule, len(config.DomainLimits))
+	for i, limit := range config.DomainLimits {
+		rules[i] = newCapRule(config, domain, fmt.Sprintf("{@%s}:%s:%d:%s", domain, config.Name, i+1, limit.CalculateTime()), limit)
+	}
+
+	return rules
+}
+
+func newCapRule(config *model.Config, name, key string, limit model.Limit) Rule {
+	amount, unit := limit.ResolveWindow()
+	return alignRule(config, unit, amount, Rule{
+		Name:   name,
+		Key:    key,
+		Limit:  limit.LimitCount,
+		Burst:  limit.LimitCount,
+		Refill: limit.LimitCount,
+		Window: limit.CalculateTime(),
+	})
+}
+
+// alignRule moves the rule to the calendar window of amount time units containing the current time,
+// when the config follows the calendar: the rule counts on a key of the window start, ending with it.
+func alignRule(config *model.Config, unit string, amount int64, rule Rule) Rule {
+	if config.ResolveAlignment() != model.AlignCalendar {
+		return rule
+	}
+
+	start, end := model.CalendarWindow(now(), config.ResolveLocation(), unit, amount)
+	rule.Key = fmt.Sprintf("%s:%s", rule.Key, start.UTC().Format("20060102T150405Z"))
+	rule.End = end
+	return rule
+}
+
+type operation string
+
+const (
+	allowOp   operation = "allow"
+	reserveOp operation = "reserve"
+	peekOp    operation = "peek"
+)
+
+// decisionFromReply parses the reply shared by the limiter scripts: 1 or 0 for the decision, the
+// (1-based) index of the decisive rule and its units in use, the milliseconds until a unit is
+// available in every rule, and the milliseconds until the decisive rule is fully available again.
+func decisionFromReply(cmd *redis.Cmd, rules []Rule, limit func(Rule) int64) (*Decision, error) {
+	values, err := int64Values(cmd, 5)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDecision(values, rules, limit)
+}
+
+// newDecision builds the decision out of the values of a limiter script reply.
+func newDecision(values []int64, rules []Rule, limit func(Rule) int64) (*Decision, error) {
+	if values[1] < 1 || values[1] > int64(len(rules)) {
+		return nil, fmt.Errorf("unexpected decisive rule: %d", values[1])
+	}
+
+	rule := rules[values[1]-1]
+	return &Decision{
+		Allowed:    values[0] == 1,
+		Rule:       rule,
+		Limit:      limit(rule),
+		Count:      values[2],
+		Remaining:  max(limit(rule)-values[2], 0),
+		RetryAfter: time.Duration(values[3]) * time.Millisecond,
+		ResetAfter: time.Duration(values[4]) * time.Millisecond,
+	}, nil
+}
+
+func ruleKeys(rules []Rule, suffix string) []string {
+	keys := make([]string, len(rules))
+	for i, rule := range rules {
+		keys[i] = rule.Key
+		if suffix != "" {
+			keys[i] = fmt.Sprintf("%s:%s", rule.Key, suffix)
+		}
+	}
+
+	return keys
+}
+
+func int64Values(cmd *redis.Cmd, size int) ([]int64, error) {
+	res, err := cmd.Result()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, ok := res.([]interface{})
+	if !ok || len(raw) != size {
+		return nil, fmt.Errorf("unexpected script reply: %v", res)
+	}
+
+	values := make([]int64, size)
+	for i, value := range raw {
+		if values[i], ok = value.(int64); !ok {
+			return nil, fmt.Errorf("unexpected script reply value: %v", value)
+		}
+	}
+
+	return values, nil
+}
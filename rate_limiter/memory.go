@@ -0,0 +1,191 @@
+package ratelimiter
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval is how often the memory store drops the entries that expired without being
+// read again.
+const memorySweepInterval = time.Minute
+
+type memoryEntry struct {
+	value     any
+	expiresAt time.Time
+}
+
+// memoryStore keeps the counters in the process memory, expiring them as Redis does. Limiters hold
+// its lock for a whole evaluation, making it as atomic as the Redis scripts.
+type memoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	deferred  map[string]time.Time
+	nextSweep time.Time
+}
+
+// NewMemoryStore builds a Store keeping its state in the process memory, for single-instance
+// deployments running without Redis. The state is lost on restart.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		entries:  make(map[string]*memoryEntry),
+		deferred: make(map[string]time.Time),
+	}
+}
+
+func (s *memoryStore) newLimiters() map[string]Limiter {
+	return newMemoryLimiters(s)
+}
+
+// get returns the entry of the key, or nil when missing or expired. Callers hold the lock.
+func (s *memoryStore) get(key string, current time.Time) *memoryEntry {
+	s.sweep(current)
+
+	entry, exists := s.entries[key]
+	if !exists {
+		return nil
+	}
+
+	if !current.Before(entry.expiresAt) {
+		delete(s.entries, key)
+		return nil
+	}
+
+	return entry
+}
+
+// set stores the value on the key, expiring after ttl. Callers hold the lock.
+func (s *memoryStore) set(key string, value any, ttl time.Duration, current time.Time) *memoryEntry {
+	entry := &memoryEntry{
+		value:     value,
+		expiresAt: current.Add(ttl),
+	}
+
+	s.entries[key] = entry
+	return entry
+}
+
+func (s *memoryStore) sweep(current time.Time) {
+	if current.Before(s.nextSweep) {
+		return
+	}
+
+	for key, entry := range s.entries {
+		if !current.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+
+	s.nextSweep = current.Add(memorySweepInterval)
+}
+
+func (s *memoryStore) deleteCounters(pattern string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := now()
+	var deleted int64
+	for key := range s.entries {
+		if globMatch(pattern, key) && s.get(key, current) != nil {
+			delete(s.entries, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (s *memoryStore) pushDeferred(member string, scheduledAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deferred[member] = scheduledAt
+	return nil
+}
+
+func (s *memoryStore) claimDue(until time.Time, count int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := make([]string, 0)
+	for member, scheduledAt := range s.deferred {
+		if scheduledAt.UnixMilli() <= until.UnixMilli() {
+			due = append(due, member)
+		}
+	}
+
+	// sorted as the Redis sorted set is: by score, then lexicographically
+	slices.SortFunc(due, func(a, b string) int {
+		if order := cmp.Compare(s.deferred[a].UnixMilli(), s.deferred[b].UnixMilli()); order != 0 {
+			return order
+		}
+
+		return strings.Compare(a, b)
+	})
+
+	if int64(len(due)) > count {
+		due = due[:count]
+	}
+
+	for _, member := range due {
+		delete(s.deferred, member)
+	}
+
+	return due, nil
+}
+
+func (s *memoryStore) remember(key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := now()
+	if s.get(key, current) != nil {
+		return false, nil
+	}
+
+	s.set(key, true, ttl, current)
+	return true, nil
+}
+
+func (s *memoryStore) forget(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *memoryStore) ping() error {
+	return nil
+}
+
+// globMatch reports whether the key matches the glob pattern, supporting the * wildcard and
+// backslash escapes, which are the only ones counterPatterns builds.
+func globMatch(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(key); i >= 0; i-- {
+				if globMatch(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+
+			return false
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+		}
+
+		if len(key) == 0 || key[0] != pattern[0] {
+			return false
+		}
+
+		pattern, key = pattern[1:], key[1:]
+	}
+
+	return len(key) == 0
+}
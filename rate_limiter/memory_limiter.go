@@ -0,0 +1,430 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"github.com/sebasir/rate-limiter-example/model"
+	"math"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+// memoryAlgorithm mirrors the scripts of an algorithm over the entries of a memory store, keyed as
+// the Redis limiter keys them. Callers hold the store lock.
+type memoryAlgorithm interface {
+	// evaluate returns the values of the script reply, consuming a unit as op tells. token
+	// identifies the unit consumed.
+	evaluate(s *memoryStore, op operation, rules []Rule, current time.Time, token string) []int64
+	// release gives the unit consumed by the decision back to every rule.
+	release(s *memoryStore, rules []Rule, decision *Decision, current time.Time)
+	// limit is the amount of units of the rule.
+	limit(rule Rule) int64
+}
+
+var memoryAlgorithms = map[string]memoryAlgorithm{
+	model.FixedWindow:      memoryFixedWindow{},
+	model.SlidingWindowLog: memorySlidingWindowLog{},
+	model.TokenBucket:      memoryTokenBucket{},
+	model.GCRA:             memoryGCRA{},
+}
+
+type memoryLimiter struct {
+	store     *memoryStore
+	algorithm memoryAlgorithm
+}
+
+func newMemoryLimiters(store *memoryStore) map[string]Limiter {
+	limiters := make(map[string]Limiter, len(memoryAlgorithms))
+	for name, algorithm := range memoryAlgorithms {
+		limiters[name] = &memoryLimiter{
+			store:     store,
+			algorithm: algorithm,
+		}
+	}
+
+	return limiters
+}
+
+func (l *memoryLimiter) Allow(rules []Rule) (*Decision, error) {
+	return l.evaluate(allowOp, rules)
+}
+
+func (l *memoryLimiter) Reserve(rules []Rule) (*Decision, error) {
+	return l.evaluate(reserveOp, rules)
+}
+
+func (l *memoryLimiter) Peek(rules []Rule) (*Decision, error) {
+	return l.evaluate(peekOp, rules)
+}
+
+func (l *memoryLimiter) evaluate(op operation, rules []Rule) (*Decision, error) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	current := now()
+	token := fmt.Sprintf("%d-%d", current.UnixNano(), rand.Int63())
+	decision, err := newDecision(l.algorithm.evaluate(l.store, op, rules, current, token), rules, l.algorithm.limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if op != peekOp {
+		decision.token = token
+	}
+
+	return decision, nil
+}
+
+func (l *memoryLimiter) Release(rules []Rule, decision *Decision) error {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+
+	l.algorithm.release(l.store, rules, decision, now())
+	return nil
+}
+
+// memoryReply builds the values of a script reply, as decisionFromReply parses them.
+type memoryReply struct {
+	allowed  bool
+	decisive int
+	fewest   int64
+	count    int64
+	retry    int64
+	reset    int64
+}
+
+func newMemoryReply(allowed bool) *memoryReply {
+	return &memoryReply{
+		allowed: allowed,
+		fewest:  math.MaxInt64,
+	}
+}
+
+// consider makes the rule decisive when it has fewer units remaining than the ones before it.
+func (r *memoryReply) consider(i int, remaining, count, reset int64) {
+	if remaining < r.fewest {
+		r.decisive, r.fewest, r.count, r.reset = i, remaining, count, reset
+	}
+}
+
+func (r *memoryReply) values() []int64 {
+	var allowed int64
+	if r.allowed {
+		allowed = 1
+	}
+
+	return []int64{allowed, int64(r.decisive + 1), r.count, r.retry, r.reset}
+}
+
+func milliseconds(ms float64) time.Duration {
+	return time.Duration(math.Ceil(ms)) * time.Millisecond
+}
+
+// memoryFixedWindow mirrors fixedWindowScript, keeping an int64 counter per rule.
+type memoryFixedWindow struct{}
+
+func (memoryFixedWindow) evaluate(s *memoryStore, op operation, rules []Rule, current time.Time, _ string) []int64 {
+	counts := make([]int64, len(rules))
+	allowed := true
+	for i, rule := range rules {
+		if entry := s.get(rule.Key, current); entry != nil {
+			counts[i], _ = entry.value.(int64)
+		}
+
+		if counts[i] >= rule.Limit {
+			allowed = false
+		}
+	}
+
+	reply := newMemoryReply(allowed)
+	for i, rule := range rules {
+		entry := s.get(rule.Key, current)
+		if op == reserveOp || (op == allowOp && allowed) {
+			counts[i]++
+			if entry == nil {
+				entry = s.set(rule.Key, counts[i], rule.expiry(current), current)
+			}
+			entry.value = counts[i]
+		}
+
+		var ttl int64
+		if entry != nil {
+			ttl = max(entry.expiresAt.Sub(current).Milliseconds(), 0)
+		}
+
+		if counts[i] >= rule.Limit {
+			reply.retry = max(reply.retry, ttl)
+		}
+
+		reply.consider(i, rule.Limit-counts[i], counts[i], ttl)
+	}
+
+	return reply.values()
+}
+
+func (memoryFixedWindow) release(s *memoryStore, rules []Rule, _ *Decision, current time.Time) {
+	for _, rule := range rules {
+		if entry := s.get(rule.Key, current); entry != nil {
+			if count, _ := entry.value.(int64); count > 0 {
+				entry.value = count - 1
+			}
+		}
+	}
+}
+
+func (memoryFixedWindow) limit(rule Rule) int64 {
+	return fixedWindowLimit(rule)
+}
+
+// memorySlidingWindowLog mirrors slidingWindowLogScript, keeping the log of every rule sorted by
+// timestamp.
+type memorySlidingWindowLog struct{}
+
+type memoryLogEntry struct {
+	at     int64
+	member string
+}
+
+type memoryLog struct {
+	entries []memoryLogEntry
+}
+
+// after returns the entries logged after start.
+func (l *memoryLog) after(start int64) []memoryLogEntry {
+	i, _ := slices.BinarySearchFunc(l.entries, start, func(entry memoryLogEntry, start int64) int {
+		if entry.at <= start {
+			return -1
+		}
+
+		return 1
+	})
+
+	return l.entries[i:]
+}
+
+func (l *memoryLog) add(at int64, member string) {
+	i, _ := slices.BinarySearchFunc(l.entries, at, func(entry memoryLogEntry, at int64) int {
+		if entry.at <= at {
+			return -1
+		}
+
+		return 1
+	})
+
+	l.entries = slices.Insert(l.entries, i, memoryLogEntry{at: at, member: member})
+}
+
+func (l *memoryLog) remove(member string) {
+	l.entries = slices.DeleteFunc(l.entries, func(entry memoryLogEntry) bool {
+		return entry.member == member
+	})
+}
+
+func (memorySlidingWindowLog) evaluate(s *memoryStore, op operation, rules []Rule, current time.Time, token string) []int64 {
+	keys := ruleKeys(rules, model.SlidingWindowLog)
+	nowMs := current.UnixMilli()
+
+	logs := make([]*memoryLog, len(rules))
+	counts := make([]int64, len(rules))
+	allowed := true
+	for i, rule := range rules {
+		logs[i] = &memoryLog{}
+		if entry := s.get(keys[i], current); entry != nil {
+			if log, ok := entry.value.(*memoryLog); ok {
+				logs[i] = log
+			}
+		}
+
+		counts[i] = int64(len(logs[i].after(nowMs - rule.Window.Milliseconds())))
+		if counts[i] >= rule.Limit {
+			allowed = false
+		}
+	}
+
+	reply := newMemoryReply(allowed)
+	for i, rule := range rules {
+		window := rule.Window.Milliseconds()
+		start := nowMs - window
+		if op != peekOp {
+			if logs[i].entries = logs[i].after(start); len(logs[i].entries) == 0 {
+				delete(s.entries, keys[i])
+			}
+
+			if allowed || op == reserveOp {
+				logs[i].add(nowMs, token)
+				s.set(keys[i], logs[i], rule.Window, current)
+				counts[i]++
+			}
+		}
+
+		entries := logs[i].after(start)
+		if counts[i] >= rule.Limit {
+			reply.retry = max(reply.retry, entries[counts[i]-rule.Limit].at+window-nowMs)
+		}
+
+		var reset int64
+		if len(entries) > 0 {
+			reset = entries[len(entries)-1].at + window - nowMs
+		}
+
+		reply.consider(i, rule.Limit-counts[i], counts[i], reset)
+	}
+
+	return reply.values()
+}
+
+func (memorySlidingWindowLog) release(s *memoryStore, rules []Rule, decision *Decision, current time.Time) {
+	for _, key := range ruleKeys(rules, model.SlidingWindowLog) {
+		if entry := s.get(key, current); entry != nil {
+			if log, ok := entry.value.(*memoryLog); ok {
+				if log.remove(decision.token); len(log.entries) == 0 {
+					delete(s.entries, key)
+				}
+			}
+		}
+	}
+}
+
+func (memorySlidingWindowLog) limit(rule Rule) int64 {
+	return fixedWindowLimit(rule)
+}
+
+// memoryTokenBucket mirrors tokenBucketScript, keeping the tokens of every rule bucket along with
+// the time of its last update.
+type memoryTokenBucket struct{}
+
+type memoryBucket struct {
+	tokens float64
+	ts     int64
+}
+
+func (memoryTokenBucket) evaluate(s *memoryStore, op operation, rules []Rule, current time.Time, _ string) []int64 {
+	keys := ruleKeys(rules, model.TokenBucket)
+	nowMs := current.UnixMilli()
+
+	tokens := make([]float64, len(rules))
+	allowed := true
+	for i, rule := range rules {
+		capacity, rate := float64(rule.Burst), tokenBucketRate(rule)
+		bucket := &memoryBucket{tokens: capacity, ts: nowMs}
+		if entry := s.get(keys[i], current); entry != nil {
+			if stored, ok := entry.value.(*memoryBucket); ok {
+				bucket = stored
+			}
+		}
+
+		tokens[i] = math.Min(capacity, bucket.tokens+float64(max(0, nowMs-bucket.ts))*rate)
+		if tokens[i] < 1 {
+			allowed = false
+		}
+	}
+
+	reply := newMemoryReply(allowed)
+	for i, rule := range rules {
+		capacity, rate := float64(rule.Burst), tokenBucketRate(rule)
+		if op != peekOp && (allowed || op == reserveOp) {
+			tokens[i]--
+			s.set(keys[i], &memoryBucket{tokens: tokens[i], ts: nowMs},
+				milliseconds(math.Max(1, (capacity-tokens[i])/rate)), current)
+		}
+
+		if tokens[i] < 1 {
+			reply.retry = max(reply.retry, int64(math.Ceil((1-tokens[i])/rate)))
+		}
+
+		available := int64(math.Floor(tokens[i]))
+		reply.consider(i, available, rule.Burst-available, int64(math.Ceil((capacity-tokens[i])/rate)))
+	}
+
+	return reply.values()
+}
+
+func (memoryTokenBucket) release(s *memoryStore, rules []Rule, _ *Decision, current time.Time) {
+	keys := ruleKeys(rules, model.TokenBucket)
+	for i, rule := range rules {
+		if entry := s.get(keys[i], current); entry != nil {
+			if bucket, ok := entry.value.(*memoryBucket); ok {
+				bucket.tokens = math.Min(bucket.tokens+1, float64(rule.Burst))
+			}
+		}
+	}
+}
+
+func (memoryTokenBucket) limit(rule Rule) int64 {
+	return tokenBucketLimit(rule)
+}
+
+// tokenBucketRate is the amount of tokens the rule bucket gets back per millisecond.
+func tokenBucketRate(rule Rule) float64 {
+	return float64(rule.Refill) / float64(rule.Window.Milliseconds())
+}
+
+// memoryGCRA mirrors gcraScript, keeping the theoretical arrival time of every rule.
+type memoryGCRA struct{}
+
+func (memoryGCRA) evaluate(s *memoryStore, op operation, rules []Rule, current time.Time, _ string) []int64 {
+	keys := ruleKeys(rules, model.GCRA)
+	nowMs := float64(current.UnixMilli())
+
+	tats := make([]float64, len(rules))
+	allowed := true
+	for i, rule := range rules {
+		tats[i] = nowMs
+		if entry := s.get(keys[i], current); entry != nil {
+			if tat, ok := entry.value.(float64); ok {
+				tats[i] = math.Max(tat, nowMs)
+			}
+		}
+
+		if tats[i]-gcraTolerance(rule) > nowMs {
+			allowed = false
+		}
+	}
+
+	reply := newMemoryReply(allowed)
+	for i, rule := range rules {
+		interval, burst := gcraInterval(rule), gcraLimit(rule)
+		if op != peekOp && (allowed || op == reserveOp) {
+			tats[i] += interval
+			s.set(keys[i], tats[i], milliseconds(tats[i]-nowMs), current)
+		}
+
+		reply.retry = max(reply.retry, int64(math.Ceil(tats[i]-gcraTolerance(rule)-nowMs)))
+		used := int64(math.Ceil((tats[i] - nowMs) / interval))
+		reply.consider(i, burst-used, used, int64(math.Ceil(tats[i]-nowMs)))
+	}
+
+	return reply.values()
+}
+
+func (memoryGCRA) release(s *memoryStore, rules []Rule, _ *Decision, current time.Time) {
+	keys := ruleKeys(rules, model.GCRA)
+	nowMs := float64(current.UnixMilli())
+	for i, rule := range rules {
+		entry := s.get(keys[i], current)
+		if entry == nil {
+			continue
+		}
+
+		tat, ok := entry.value.(float64)
+		if !ok {
+			continue
+		}
+
+		if tat -= gcraInterval(rule); tat > nowMs {
+			s.set(keys[i], tat, milliseconds(tat-nowMs), current)
+		} else {
+			delete(s.entries, keys[i])
+		}
+	}
+}
+
+func (memoryGCRA) limit(rule Rule) int64 {
+	return gcraLimit(rule)
+}
+
+// gcraTolerance is how far ahead of now the TAT of the rule may be for a send to be allowed, in
+// milliseconds.
+func gcraTolerance(rule Rule) float64 {
+	return gcraInterval(rule) * float64(gcraLimit(rule)-1)
+}
@@ -0,0 +1,70 @@
+package ratelimiter
+
+import (
+	"github.com/sebasir/rate-limiter-example/model"
+	"go.uber.org/zap"
+)
+
+// GetQuota peeks the limits of the recipient for the notification type, without consuming them.
+func (c *client) GetQuota(recipient, notificationType string) (*model.Quota, error) {
+	c.logger.Debug("retrieving recipient quota", zap.String("recipient", recipient),
+		zap.String("notification_type", notificationType))
+
+	quota := &model.Quota{
+		Recipient:        recipient,
+		NotificationType: notificationType,
+	}
+
+	_, limiter, rules, err := c.limitsFor(recipient, notificationType)
+	if err != nil {
+		return nil, err
+	}
+
+	if limiter == nil {
+		quota.Unlimited = true
+		return quota, nil
+	}
+
+	decision, err := limiter.Peek(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	resetAt := now().Add(decision.ResetAfter)
+	quota.LimitName = decision.Rule.Name
+	quota.Window = decision.Rule.Window.String()
+	quota.Limit = decision.Limit
+	quota.Count = decision.Count
+	quota.Remaining = decision.Remaining
+	quota.ResetAt = &resetAt
+	return quota, nil
+}
+
+// ListQuotas peeks the quota of the recipient for every notification type, leaving out the configs
+// capping groups of types.
+func (c *client) ListQuotas(recipient string) ([]*model.Quota, error) {
+	configs, err := c.manager.ListNotificationConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string]bool{model.GlobalGroup: true}
+	for _, config := range configs {
+		groups[config.Group] = true
+	}
+
+	quotas := make([]*model.Quota, 0, len(configs))
+	for _, config := range configs {
+		if groups[config.Name] {
+			continue
+		}
+
+		quota, err := c.GetQuota(recipient, config.Name)
+		if err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, quota)
+	}
+
+	return quotas, nil
+}
@@ -3,13 +3,14 @@ package ratelimiter
 import (
 	"errors"
 	"fmt"
-	"github.com/go-redis/redis"
 	. "github.com/sebasir/rate-limiter-example/app_errors"
 	"github.com/sebasir/rate-limiter-example/manager"
 	"github.com/sebasir/rate-limiter-example/model"
 	pb "github.com/sebasir/rate-limiter-example/notification/proto"
 	"github.com/sebasir/rate-limiter-example/service"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"strings"
 	"time"
 )
 
@@ -20,83 +21,369 @@ var InternalErrorResult = &pb.Result{
 
 var ErrProcessingNotificationRequest = errors.New("error processing notification request")
 
+// UnavailableResult rejects the notifications while the store is unavailable, unless their
+// degradation policy tells otherwise.
+var UnavailableResult = &pb.Result{
+	Status:          pb.Status_REJECTED,
+	ResponseMessage: "notification to recipient was rejected, rate limiter is unavailable",
+}
+
+// errStoreUnavailable tells the limits could not be evaluated because the store is unavailable.
+var errStoreUnavailable = errors.New("rate limiter store is unavailable")
+
+var now = time.Now
+
 type client struct {
 	delegate service.Client
 	manager  manager.Service
-	rdb      redis.Cmdable
-	logger   *zap.Logger
+	store    Store
+	limiters map[string]Limiter
+	// fallback evaluates the limits of the FALLBACK types while the store is unavailable.
+	fallback map[string]Limiter
+	health   *healthDetector
+	// degradationPolicy applies to the types without one, and when their config cannot be loaded.
+	degradationPolicy string
+	logger            *zap.Logger
 }
 
-func NewClient(rdb redis.Cmdable, delegate service.Client, manager manager.Service) service.ExtendedClient {
+func NewClient(store Store, delegate service.Client, manager manager.Service, degradationPolicy string) service.ExtendedClient {
 	return &client{
-		delegate: delegate,
-		manager:  manager,
-		rdb:      rdb,
-		logger:   zap.L(),
+		delegate:          delegate,
+		manager:           manager,
+		store:             store,
+		limiters:          store.newLimiters(),
+		fallback:          NewMemoryStore().newLimiters(),
+		health:            newHealthDetector(store),
+		degradationPolicy: degradationPolicy,
+		logger:            zap.L(),
 	}
 }
 
 func (c *client) Send(n *pb.Notification) (*pb.Result, error) {
+	return c.send(n, true)
+}
+
+// redeliver sends the deferred notification again. It skips deduplication, as its fingerprint was
+// claimed when it was first sent.
+func (c *client) redeliver(n *pb.Notification) (*pb.Result, error) {
+	return c.send(n, false)
+}
+
+func (c *client) send(n *pb.Notification, deduplicate bool) (*pb.Result, error) {
 	recipientField := zap.String("recipient", n.Recipient)
 
 	c.logger.Debug("sending notification", recipientField)
 
-	config, err := c.manager.GetByName(n.NotificationType)
+	config, limiter, rules, err := c.limitsFor(n.Recipient, n.NotificationType)
 	if err != nil {
-		return InternalErrorResult, LogAndError("error trying to fetch notification type configuration",
-			errors.Join(err, ErrProcessingNotificationRequest), c.logger, zap.String("notification_type", n.NotificationType))
+		if c.health.failed(err) {
+			return c.degrade(n, nil)
+		}
+		return InternalErrorResult, err
+	}
+
+	if config == nil || !deduplicate {
+		return c.enforce(n, config, limiter, rules)
+	}
+
+	key, duplicate := c.deduplicate(n, config)
+	if duplicate {
+		return deduplicatedResult(config), nil
+	}
+
+	res, err := c.enforce(n, config, limiter, rules)
+	c.release(key, res)
+	return res, err
+}
+
+// enforce holds the notification back during quiet hours, or delivers it as the limits tell.
+func (c *client) enforce(n *pb.Notification, config *model.Config, limiter Limiter, rules []Rule) (*pb.Result, error) {
+	recipientField := zap.String("recipient", n.Recipient)
+	if config != nil {
+		if quiet, until := config.QuietHoursAt(now()); quiet != nil {
+			if config.ResolveMode() != model.Shadow {
+				return c.holdQuietHours(n, quiet, until)
+			}
+
+			c.logger.Info("notification would have been held by quiet hours", recipientField, zap.Time("until", until))
+		}
+	}
+
+	if limiter == nil {
+		c.logger.Debug("sending notification without limits to gRPC delegate", recipientField)
+		return c.deliver(n)
+	}
+
+	decision, limiter, err := c.evaluate(config, limiter, rules)
+	if errors.Is(err, errStoreUnavailable) {
+		return c.degrade(n, config)
+	}
+
+	if err != nil {
+		return InternalErrorResult, err
+	}
+
+	countField := zap.Int64("request_count", decision.Count)
+	configField := zap.String("notification_config", decision.Rule.Name)
+	windowField := zap.Duration("window", decision.Rule.Window)
+	ttlField := zap.Duration("ttl", decision.ResetAfter)
+
+	if !decision.Allowed && config.ResolveMode() != model.Shadow {
+		if config.ResolveOverflowPolicy() == model.OverflowDefer {
+			res, err := c.deferNotification(n, decision)
+			if !errors.Is(err, errStoreUnavailable) {
+				return res, err
+			}
+		}
+
+		c.logger.Debug("rejecting notification", countField, recipientField, configField, windowField, ttlField)
+		return withQuota(&pb.Result{
+			Status:          pb.Status_REJECTED,
+			ResponseMessage: fmt.Sprintf("notification to recipient was rejected, %s %s window limit was reached", decision.Rule.Name, decision.Rule.Window),
+		}, decision), nil
+	}
+
+	if !decision.Allowed {
+		c.logger.Info("notification would have been rejected", countField, recipientField, configField, windowField, ttlField)
+		if err := c.manager.IncrementShadowRejections(config.Name); err != nil {
+			c.logger.Warn("error trying to count shadow rejection", zap.Error(err), recipientField)
+		}
+	}
+
+	c.logger.Debug("sending notification to gRPC delegate", countField, recipientField, configField, windowField, ttlField)
+	res, err := c.deliver(n)
+	// would-be rejections only consumed a unit when every attempt counts
+	consumed := decision.Allowed || config.ResolveCountingPolicy() == model.CountAll
+	if config.RefundOnFailure && consumed && res.GetStatus() == pb.Status_INTERNAL_ERROR {
+		c.logger.Debug("refunding notification unit", recipientField, configField)
+		if err := limiter.Release(rules, decision); err != nil {
+			c.logger.Warn("error trying to refund notification unit", zap.Error(err), recipientField)
+		}
+	}
+
+	if err == nil && res.GetStatus() == pb.Status_SENT {
+		return withQuota(proto.Clone(res).(*pb.Result), decision), nil
+	}
+
+	return res, err
+}
+
+// evaluate runs the rules through the limiter while the store is available. Otherwise, the FALLBACK
+// types run them through the fallback limiter instead, returning the limiter used.
+func (c *client) evaluate(config *model.Config, limiter Limiter, rules []Rule) (*Decision, Limiter, error) {
+	if c.health.available() {
+		decision, err := consume(config, limiter)(rules)
+		if !c.health.failed(err) {
+			return decision, limiter, err
+		}
+	}
+
+	if c.resolveDegradationPolicy(config) != model.Fallback {
+		return nil, nil, errStoreUnavailable
+	}
+
+	c.logger.Debug("evaluating notification limits on fallback limiter", zap.String("notification_config", config.Name))
+	limiter = c.fallback[config.ResolveAlgorithm()]
+	decision, err := consume(config, limiter)(rules)
+	return decision, limiter, err
+}
+
+// consume returns the limiter operation consuming units as the counting policy of the config tells.
+func consume(config *model.Config, limiter Limiter) func(rules []Rule) (*Decision, error) {
+	if config.ResolveCountingPolicy() == model.CountAll {
+		return limiter.Reserve
+	}
+
+	return limiter.Allow
+}
+
+// degrade handles the notification as its degradation policy tells while the store is unavailable.
+// FALLBACK notifications end up here only when their config could not be loaded, neither from the
+// cache, to build the rules, so they are rejected.
+func (c *client) degrade(n *pb.Notification, config *model.Config) (*pb.Result, error) {
+	recipientField := zap.String("recipient", n.Recipient)
+	policy := c.resolveDegradationPolicy(config)
+	if policy == model.FailOpen {
+		c.logger.Warn("sending notification without limits, rate limiter store is unavailable", recipientField)
+		return c.deliver(n)
+	}
+
+	c.logger.Warn("rejecting notification, rate limiter store is unavailable", recipientField, zap.String("policy", policy))
+	return UnavailableResult, nil
+}
+
+// resolveDegradationPolicy returns the degradation policy of the config, or the default one of the
+// client when the config has none or is missing.
+func (c *client) resolveDegradationPolicy(config *model.Config) string {
+	if config == nil || config.DegradationPolicy == "" {
+		return c.degradationPolicy
 	}
 
-	key := fmt.Sprintf("%s:%s", n.Recipient, n.NotificationType)
-	intCmd := c.rdb.Incr(key)
+	return config.DegradationPolicy
+}
+
+// withQuota fills the rate limit state of the decision in the result.
+func withQuota(res *pb.Result, decision *Decision) *pb.Result {
+	res.Limit = decision.Limit
+	res.Remaining = decision.Remaining
+	res.ResetAfterMs = decision.ResetAfter.Milliseconds()
+	res.RetryAfterMs = decision.RetryAfter.Milliseconds()
+	return res
+}
+
+// limitsFor resolves the configuration, limiter and rules the recipient is subject to for the
+// notification type, after applying its override. The limiter is nil when the recipient is exempt
+// or the type disabled. Exempt recipients still get the config, if any, as its quiet hours and
+// deduplication are no limits.
+func (c *client) limitsFor(recipient, notificationType string) (*model.Config, Limiter, []Rule, error) {
+	recipientField := zap.String("recipient", recipient)
 
-	count, err := intCmd.Result()
+	override, err := c.overrideFor(recipient, notificationType)
 	if err != nil {
-		return InternalErrorResult, LogAndError("error trying to persist count in cache",
+		return nil, nil, nil, LogAndError("error trying to fetch recipient override",
 			errors.Join(err, ErrProcessingNotificationRequest), c.logger, recipientField)
 	}
 
-	keyField := zap.String("key", key)
-	var ttl time.Duration
-	timeWindow := config.CalculateTime()
-	if count == 1 {
-		boolCmd := c.rdb.Expire(key, timeWindow)
-		_, err = boolCmd.Result()
-		if err != nil {
-			return InternalErrorResult, LogAndError("error trying to submit expiration",
-				errors.Join(err, ErrProcessingNotificationRequest), c.logger, keyField)
+	config, err := c.manager.GetByName(notificationType)
+	if override != nil && override.Exempt {
+		c.logger.Debug("recipient is exempt from limits", recipientField)
+		if err != nil && !errors.Is(err, manager.ErrNotificationConfigNotFound) {
+			c.logger.Warn("error trying to fetch notification type configuration, skipping quiet hours and deduplication of exempt recipient",
+				zap.Error(err), recipientField)
 		}
-		ttl = timeWindow
-	} else {
-		durationCmd := c.rdb.TTL(key)
-		ttl, err = durationCmd.Result()
+
 		if err != nil {
-			return InternalErrorResult, LogAndError("error trying to acquire current TTL",
-				errors.Join(err, ErrProcessingNotificationRequest), c.logger, keyField)
+			return nil, nil, nil, nil
 		}
+		return config, nil, nil, nil
+	}
+
+	if err != nil {
+		return nil, nil, nil, LogAndError("error trying to fetch notification type configuration",
+			errors.Join(err, ErrProcessingNotificationRequest), c.logger, zap.String("notification_type", notificationType))
 	}
 
-	countField := zap.Int64("request_count", count)
-	configField := zap.String("notification_config", config.Name)
-	ttlField := zap.Duration("ttl", ttl)
+	if override != nil {
+		c.logger.Debug("applying recipient override", recipientField, zap.String("scope", override.Scope()))
+		config = override.Apply(config)
+	}
 
-	if count > config.LimitCount {
-		c.logger.Debug("rejecting notification", countField, recipientField, configField, ttlField)
-		return &pb.Result{
-			Status:          pb.Status_REJECTED,
-			ResponseMessage: "notification to recipient was rejected",
-		}, nil
+	if config.ResolveMode() == model.Disabled {
+		c.logger.Debug("notification type limits are disabled", recipientField)
+		return config, nil, nil, nil
+	}
+
+	algorithm := config.ResolveAlgorithm()
+	limiter, exists := c.limiters[algorithm]
+	if !exists {
+		return nil, nil, nil, LogAndError("error trying to resolve rate limiting algorithm",
+			ErrProcessingNotificationRequest, c.logger, zap.String("algorithm", algorithm))
+	}
+
+	rules, err := c.recipientRules(recipient, notificationType, config)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	c.logger.Debug("sending notification to gRPC delegate", countField, recipientField, configField, ttlField)
+	return config, limiter, rules, nil
+}
+
+// overrideFor returns the override of the recipient for the notification type. Overrides are
+// ignored while Redis is unavailable, as they may have not been cached, letting the notification
+// type config tell how to degrade.
+func (c *client) overrideFor(recipient, notificationType string) (*model.Override, error) {
+	if !c.health.available() {
+		return nil, nil
+	}
+
+	override, err := c.manager.GetOverride(recipient, notificationType)
+	if c.health.failed(err) {
+		c.logger.Warn("ignoring recipient override, rate limiter store is unavailable", zap.Error(err),
+			zap.String("recipient", recipient))
+		return nil, nil
+	}
+
+	return override, err
+}
+
+func (c *client) deliver(n *pb.Notification) (*pb.Result, error) {
 	res, err := c.delegate.Send(n)
+	if errors.Is(err, ErrCircuitOpen) {
+		c.logger.Warn("notification service circuit breaker is open, failing fast", zap.String("recipient", n.Recipient))
+		return CircuitOpenResult, errors.Join(err, ErrProcessingNotificationRequest)
+	}
+
 	if err != nil {
 		return InternalErrorResult, LogAndError("error trying to send notification",
-			errors.Join(err, ErrProcessingNotificationRequest), c.logger, recipientField)
+			errors.Join(err, ErrProcessingNotificationRequest), c.logger, zap.String("recipient", n.Recipient))
 	}
 	return res, nil
 }
 
+// recipientRules builds the rules of the notification type for the recipient, followed by the
+// rules of its email domain and of the caps it is subject to: its group's and the GLOBAL one,
+// when configured, fetched at once. Caps not loaded while Redis is unavailable are skipped, so the
+// FALLBACK types are limited by their own rules.
+func (c *client) recipientRules(recipient, notificationType string, config *model.Config) ([]Rule, error) {
+	rules := newRules(counterKey(recipient, notificationType), config)
+	if len(config.DomainLimits) > 0 {
+		rules = append(rules, newDomainRules(recipientDomain(recipient), config)...)
+	}
+
+	groups := []string{model.GlobalGroup}
+	if config.Group != "" && config.Group != model.GlobalGroup {
+		groups = append([]string{config.Group}, groups...)
+	}
+
+	caps, err := c.manager.GetByNames(groups)
+	if c.health.failed(err) {
+		c.logger.Warn("skipping notification caps, rate limiter store is unavailable", zap.Error(err),
+			zap.Strings("groups", groups))
+		return rules, nil
+	}
+
+	if err != nil {
+		return nil, LogAndError("error trying to fetch notification cap configurations",
+			errors.Join(err, ErrProcessingNotificationRequest), c.logger, zap.Strings("groups", groups))
+	}
+
+	for _, group := range groups {
+		if capConfig, exists := caps[group]; exists {
+			rules = append(rules, newCapRules(counterKey(recipient, "GROUP:"+group), capConfig)...)
+		}
+	}
+
+	return rules, nil
+}
+
+// counterKey is the key of the counters of the recipient for name, hash tagged by the recipient so
+// every counter evaluated along with it lands in the same Redis Cluster slot.
+func counterKey(recipient, name string) string {
+	return fmt.Sprintf("{%s}:%s", recipient, name)
+}
+
+func recipientDomain(recipient string) string {
+	return strings.ToLower(recipient[strings.LastIndex(recipient, "@")+1:])
+}
+
+// Status reports whether the store is available, along with the state of the circuit breaker of the
+// delegate, if any.
+func (c *client) Status() *model.Status {
+	status := &model.Status{
+		Store: model.StatusUp,
+	}
+
+	if !c.health.available() {
+		status.Store = model.StatusDown
+	}
+
+	if breaker, ok := c.delegate.(CircuitBreaker); ok {
+		status.Delegate = breaker.Status()
+	}
+
+	return status
+}
+
 func (c *client) ListNotificationConfig() ([]*model.Config, error) {
 	return c.manager.ListNotificationConfig()
 }
@@ -104,3 +391,19 @@ func (c *client) ListNotificationConfig() ([]*model.Config, error) {
 func (c *client) PersistNotificationConfig(config *model.Config) error {
 	return c.manager.PersistNotificationConfig(config)
 }
+
+func (c *client) ListShadowRejections() (map[string]int64, error) {
+	return c.manager.ListShadowRejections()
+}
+
+func (c *client) ListRecipientOverrides(recipient string) ([]*model.Override, error) {
+	return c.manager.ListRecipientOverrides(recipient)
+}
+
+func (c *client) PersistRecipientOverride(override *model.Override) error {
+	return c.manager.PersistRecipientOverride(override)
+}
+
+func (c *client) DeleteRecipientOverride(recipient, notificationType string) error {
+	return c.manager.DeleteRecipientOverride(recipient, notificationType)
+}
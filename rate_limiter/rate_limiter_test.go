@@ -2,6 +2,8 @@ package ratelimiter
 
 import (
 	"errors"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis"
 	. "github.com/ovechkin-dm/mockio/mock"
 	"github.com/sebasir/rate-limiter-example/manager"
@@ -9,6 +11,9 @@ import (
 	pb "github.com/sebasir/rate-limiter-example/notification/proto"
 	"github.com/sebasir/rate-limiter-example/service"
 	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net"
 	"reflect"
 	"testing"
 	"time"
@@ -33,47 +38,75 @@ type testCase struct {
 	targetErr error
 }
 
-type redisCmd[T int64 | bool | time.Duration] struct {
+type redisCmd[T []interface{} | string] struct {
 	val     T
 	err     error
 	exclude bool
 }
 
 type redisMock struct {
-	incrCmd   redisCmd[int64]
-	expireCmd redisCmd[bool]
-	ttlCmd    redisCmd[time.Duration]
+	evalCmd    redisCmd[[]interface{}]
+	releaseCmd *redisCmd[[]interface{}]
 }
 
 func (r *redisMock) buildRedisMock() redis.Cmdable {
 	rdbMock := Mock[redis.Cmdable]()
 
-	if !r.incrCmd.exclude {
-		When(rdbMock.Incr(AnyString())).
-			ThenReturn(redis.NewIntResult(r.incrCmd.val, r.incrCmd.err))
+	if r.releaseCmd != nil {
+		When(rdbMock.EvalSha(Exact(fixedWindowReleaseScript.hash), Any[[]string](), Any[[]interface{}]()...)).
+			ThenReturn(redis.NewCmdResult(r.releaseCmd.val, r.releaseCmd.err))
 	}
 
-	if !r.ttlCmd.exclude {
-		When(rdbMock.TTL(AnyString())).
-			ThenReturn(redis.NewDurationResult(r.ttlCmd.val, r.ttlCmd.err))
-	}
-
-	if !r.expireCmd.exclude {
-		When(rdbMock.Expire(AnyString(), Any[time.Duration]())).
-			ThenReturn(redis.NewBoolResult(r.expireCmd.val, r.expireCmd.err))
+	if !r.evalCmd.exclude {
+		When(rdbMock.EvalSha(AnyString(), Any[[]string](), Any[[]interface{}]()...)).
+			ThenReturn(redis.NewCmdResult(r.evalCmd.val, r.evalCmd.err))
 	}
 
 	return rdbMock
 }
 
 type managerMock struct {
-	config       *model.Config
-	getByNameErr error
+	config           *model.Config
+	getByNameErr     error
+	getByNameExclude bool
+	caps             map[string]*model.Config
+	capErr           error
+	override         *model.Override
+	overrideErr      error
+	shadow           bool
+	shadowErr        error
 }
 
 func (m *managerMock) buildManagerMock() manager.Service {
 	mgrMock := Mock[manager.Service]()
-	When(mgrMock.GetByName(AnyString())).ThenReturn(m.config, m.getByNameErr)
+	When(mgrMock.GetOverride(AnyString(), AnyString())).ThenReturn(m.override, m.overrideErr)
+	if m.shadow {
+		When(mgrMock.IncrementShadowRejections(AnyString())).ThenReturn(m.shadowErr)
+	}
+	if m.getByNameExclude {
+		return mgrMock
+	}
+
+	WhenDouble(mgrMock.GetByName(AnyString())).ThenReturn(m.config, m.getByNameErr)
+	if m.caps == nil && m.capErr == nil {
+		// no caps are found when not stubbed
+		return mgrMock
+	}
+
+	WhenDouble(mgrMock.GetByNames(Any[[]string]())).ThenAnswer(func(args []any) (map[string]*model.Config, error) {
+		if m.capErr != nil {
+			return nil, m.capErr
+		}
+
+		caps := make(map[string]*model.Config)
+		for _, name := range args[0].([]string) {
+			if capConfig, exists := m.caps[name]; exists {
+				caps[name] = capConfig
+			}
+		}
+
+		return caps, nil
+	})
 
 	return mgrMock
 }
@@ -90,6 +123,17 @@ func (d *delegateMock) buildDelegateMock() service.Client {
 	return dlgMock
 }
 
+func sentResult(limit, remaining, resetAfterMs, retryAfterMs int64) *pb.Result {
+	return &pb.Result{
+		Status:          pb.Status_SENT,
+		ResponseMessage: "notification sent to recipient",
+		Limit:           limit,
+		Remaining:       remaining,
+		ResetAfterMs:    resetAfterMs,
+		RetryAfterMs:    retryAfterMs,
+	}
+}
+
 func getTestCases() []*testCase {
 	okNotification := &pb.Notification{
 		Recipient:        "a@a.a",
@@ -113,6 +157,124 @@ func getTestCases() []*testCase {
 		config: okConfig,
 	}).buildManagerMock()
 
+	slidingWindowLogMgr := (&managerMock{
+		config: &model.Config{
+			Name:       "Newsletter",
+			LimitCount: 1,
+			TimeAmount: 1,
+			TimeUnit:   "MINUTE",
+			Algorithm:  model.SlidingWindowLog,
+		},
+	}).buildManagerMock()
+
+	tokenBucketMgr := (&managerMock{
+		config: &model.Config{
+			Name:          "Newsletter",
+			LimitCount:    1,
+			TimeAmount:    10,
+			TimeUnit:      "MINUTE",
+			Algorithm:     model.TokenBucket,
+			BurstCapacity: 5,
+			RefillRate:    1,
+		},
+	}).buildManagerMock()
+
+	stackedLimitsMgr := (&managerMock{
+		config: &model.Config{
+			Name:       "Newsletter",
+			LimitCount: 2,
+			TimeAmount: 1,
+			TimeUnit:   "MINUTE",
+			Limits: []model.Limit{{
+				LimitCount: 20,
+				TimeAmount: 1,
+				TimeUnit:   "DAY",
+			}},
+		},
+	}).buildManagerMock()
+
+	gcraMgr := (&managerMock{
+		config: &model.Config{
+			Name:       "Newsletter",
+			LimitCount: 2,
+			TimeAmount: 1,
+			TimeUnit:   "MINUTE",
+			Algorithm:  model.GCRA,
+		},
+	}).buildManagerMock()
+
+	globalCapMgr := (&managerMock{
+		config: okConfig,
+		caps: map[string]*model.Config{
+			model.GlobalGroup: {
+				Name:       model.GlobalGroup,
+				LimitCount: 5,
+				TimeAmount: 1,
+				TimeUnit:   "DAY",
+			},
+		},
+	}).buildManagerMock()
+
+	groupCapMgr := (&managerMock{
+		config: &model.Config{
+			Name:       "Newsletter",
+			LimitCount: 1,
+			TimeAmount: 1,
+			TimeUnit:   "MINUTE",
+			Group:      "Marketing",
+		},
+		caps: map[string]*model.Config{
+			"Marketing": {
+				Name:       "Marketing",
+				LimitCount: 3,
+				TimeAmount: 1,
+				TimeUnit:   "HOUR",
+			},
+		},
+	}).buildManagerMock()
+
+	overrideMgr := (&managerMock{
+		config: okConfig,
+		override: &model.Override{
+			Recipient:  "a@a.a",
+			LimitCount: 10,
+			TimeAmount: 1,
+			TimeUnit:   "HOUR",
+		},
+	}).buildManagerMock()
+
+	domainLimitsMgr := (&managerMock{
+		config: &model.Config{
+			Name:       "Newsletter",
+			LimitCount: 1,
+			TimeAmount: 1,
+			TimeUnit:   "MINUTE",
+			DomainLimits: []model.Limit{{
+				LimitCount: 100,
+				TimeAmount: 1,
+				TimeUnit:   "HOUR",
+			}},
+		},
+	}).buildManagerMock()
+
+	refundMgr := (&managerMock{
+		config: &model.Config{
+			Name:            "Newsletter",
+			LimitCount:      1,
+			TimeAmount:      1,
+			TimeUnit:        "MINUTE",
+			RefundOnFailure: true,
+		},
+	}).buildManagerMock()
+
+	shadowConfig := &model.Config{
+		Name:       "Newsletter",
+		LimitCount: 1,
+		TimeAmount: 1,
+		TimeUnit:   "MINUTE",
+		Mode:       model.Shadow,
+	}
+
 	redisErr := errors.New("redis: error")
 
 	return []*testCase{
@@ -120,9 +282,7 @@ func getTestCases() []*testCase {
 			name: "OK_Notification_Send",
 			fields: fields{
 				rdb: (&redisMock{
-					incrCmd:   redisCmd[int64]{val: 1},
-					expireCmd: redisCmd[bool]{val: true},
-					ttlCmd:    redisCmd[time.Duration]{exclude: true},
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(0), int64(60000)}},
 				}).buildRedisMock(),
 				delegate: (&delegateMock{
 					result: okResponse,
@@ -130,85 +290,513 @@ func getTestCases() []*testCase {
 				manager: okMgr,
 			},
 			args: okNotification,
-			want: okResponse,
+			want: sentResult(1, 0, 60000, 0),
 		}, {
 			name: "OK_Notification Rejected",
 			fields: fields{
 				rdb: (&redisMock{
-					incrCmd:   redisCmd[int64]{val: 2},
-					expireCmd: redisCmd[bool]{exclude: true},
-					ttlCmd:    redisCmd[time.Duration]{val: time.Minute},
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(1), int64(2), int64(60000), int64(60000)}},
 				}).buildRedisMock(),
 				manager: okMgr,
 			},
 			args: okNotification,
 			want: &pb.Result{
 				Status:          pb.Status_REJECTED,
-				ResponseMessage: "notification to recipient was rejected",
+				ResponseMessage: "notification to recipient was rejected, Newsletter 1m0s window limit was reached",
+				Limit:           1,
+				ResetAfterMs:    60000,
+				RetryAfterMs:    60000,
 			},
 		}, {
-			name: "ERROR_Unknown_Notification_Config",
+			name: "OK_Sliding_Window_Log_Send",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(0), int64(60000)}},
+				}).buildRedisMock(),
+				delegate: (&delegateMock{
+					result: okResponse,
+				}).buildDelegateMock(),
+				manager: slidingWindowLogMgr,
+			},
+			args: okNotification,
+			want: sentResult(1, 0, 60000, 0),
+		}, {
+			name: "OK_Sliding_Window_Log_Rejected",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(1), int64(1), int64(1500), int64(30000)}},
+				}).buildRedisMock(),
+				manager: slidingWindowLogMgr,
+			},
+			args: okNotification,
+			want: &pb.Result{
+				Status:          pb.Status_REJECTED,
+				ResponseMessage: "notification to recipient was rejected, Newsletter 1m0s window limit was reached",
+				Limit:           1,
+				ResetAfterMs:    30000,
+				RetryAfterMs:    1500,
+			},
+		}, {
+			name: "OK_Token_Bucket_Send",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(0), int64(600000)}},
+				}).buildRedisMock(),
+				delegate: (&delegateMock{
+					result: okResponse,
+				}).buildDelegateMock(),
+				manager: tokenBucketMgr,
+			},
+			args: okNotification,
+			want: sentResult(5, 4, 600000, 0),
+		}, {
+			name: "OK_Token_Bucket_Rejected",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(1), int64(5), int64(120000), int64(3000000)}},
+				}).buildRedisMock(),
+				manager: tokenBucketMgr,
+			},
+			args: okNotification,
+			want: &pb.Result{
+				Status:          pb.Status_REJECTED,
+				ResponseMessage: "notification to recipient was rejected, Newsletter 10m0s window limit was reached",
+				Limit:           5,
+				ResetAfterMs:    3000000,
+				RetryAfterMs:    120000,
+			},
+		}, {
+			name: "OK_GCRA_Send",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(30000), int64(30000)}},
+				}).buildRedisMock(),
+				delegate: (&delegateMock{
+					result: okResponse,
+				}).buildDelegateMock(),
+				manager: gcraMgr,
+			},
+			args: okNotification,
+			want: sentResult(1, 0, 30000, 30000),
+		}, {
+			name: "OK_GCRA_Rejected",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(1), int64(1), int64(12500), int64(12500)}},
+				}).buildRedisMock(),
+				manager: gcraMgr,
+			},
+			args: okNotification,
+			want: &pb.Result{
+				Status:          pb.Status_REJECTED,
+				ResponseMessage: "notification to recipient was rejected, Newsletter 1m0s window limit was reached",
+				Limit:           1,
+				ResetAfterMs:    12500,
+				RetryAfterMs:    12500,
+			},
+		}, {
+			name: "OK_Stacked_Limits_Send",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(2), int64(60000), int64(60000)}},
+				}).buildRedisMock(),
+				delegate: (&delegateMock{
+					result: okResponse,
+				}).buildDelegateMock(),
+				manager: stackedLimitsMgr,
+			},
+			args: okNotification,
+			want: sentResult(2, 0, 60000, 60000),
+		}, {
+			name: "OK_Stacked_Limits_Rejected",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(2), int64(20), int64(3600000), int64(3600000)}},
+				}).buildRedisMock(),
+				manager: stackedLimitsMgr,
+			},
+			args: okNotification,
+			want: &pb.Result{
+				Status:          pb.Status_REJECTED,
+				ResponseMessage: "notification to recipient was rejected, Newsletter 24h0m0s window limit was reached",
+				Limit:           20,
+				ResetAfterMs:    3600000,
+				RetryAfterMs:    3600000,
+			},
+		}, {
+			name: "OK_Global_Cap_Send",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(60000), int64(60000)}},
+				}).buildRedisMock(),
+				delegate: (&delegateMock{
+					result: okResponse,
+				}).buildDelegateMock(),
+				manager: globalCapMgr,
+			},
+			args: okNotification,
+			want: sentResult(1, 0, 60000, 60000),
+		}, {
+			name: "OK_Global_Cap_Rejected",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(2), int64(5), int64(3600000), int64(3600000)}},
+				}).buildRedisMock(),
+				manager: globalCapMgr,
+			},
+			args: okNotification,
+			want: &pb.Result{
+				Status:          pb.Status_REJECTED,
+				ResponseMessage: "notification to recipient was rejected, GLOBAL 24h0m0s window limit was reached",
+				Limit:           5,
+				ResetAfterMs:    3600000,
+				RetryAfterMs:    3600000,
+			},
+		}, {
+			name: "OK_Group_Cap_Rejected",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(2), int64(3), int64(1800000), int64(1800000)}},
+				}).buildRedisMock(),
+				manager: groupCapMgr,
+			},
+			args: okNotification,
+			want: &pb.Result{
+				Status:          pb.Status_REJECTED,
+				ResponseMessage: "notification to recipient was rejected, Marketing 1h0m0s window limit was reached",
+				Limit:           3,
+				ResetAfterMs:    1800000,
+				RetryAfterMs:    1800000,
+			},
+		}, {
+			name: "OK_Domain_Limits_Send",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(60000), int64(60000)}},
+				}).buildRedisMock(),
+				delegate: (&delegateMock{
+					result: okResponse,
+				}).buildDelegateMock(),
+				manager: domainLimitsMgr,
+			},
+			args: okNotification,
+			want: sentResult(1, 0, 60000, 60000),
+		}, {
+			name: "OK_Domain_Limits_Rejected",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(2), int64(100), int64(900000), int64(900000)}},
+				}).buildRedisMock(),
+				manager: domainLimitsMgr,
+			},
+			args: okNotification,
+			want: &pb.Result{
+				Status:          pb.Status_REJECTED,
+				ResponseMessage: "notification to recipient was rejected, a.a 1h0m0s window limit was reached",
+				Limit:           100,
+				ResetAfterMs:    900000,
+				RetryAfterMs:    900000,
+			},
+		}, {
+			name: "OK_Shadow_Send",
 			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(0), int64(60000)}},
+				}).buildRedisMock(),
+				delegate: (&delegateMock{
+					result: okResponse,
+				}).buildDelegateMock(),
 				manager: (&managerMock{
-					getByNameErr: redisErr,
+					config: shadowConfig,
 				}).buildManagerMock(),
 			},
-			args:      okNotification,
-			want:      InternalErrorResult,
-			wantErr:   true,
-			targetErr: ErrProcessingNotificationRequest,
+			args: okNotification,
+			want: sentResult(1, 0, 60000, 0),
 		}, {
-			name: "ERROR_Redis_Incr",
+			name: "OK_Shadow_Rejected_Send",
 			fields: fields{
 				rdb: (&redisMock{
-					incrCmd:   redisCmd[int64]{err: redisErr},
-					expireCmd: redisCmd[bool]{exclude: true},
-					ttlCmd:    redisCmd[time.Duration]{exclude: true},
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(1), int64(1), int64(60000), int64(60000)}},
 				}).buildRedisMock(),
-				manager: okMgr,
+				delegate: (&delegateMock{
+					result: okResponse,
+				}).buildDelegateMock(),
+				manager: (&managerMock{
+					config: shadowConfig,
+					shadow: true,
+				}).buildManagerMock(),
 			},
-			args:      okNotification,
-			want:      InternalErrorResult,
-			wantErr:   true,
-			targetErr: ErrProcessingNotificationRequest,
+			args: okNotification,
+			want: sentResult(1, 0, 60000, 60000),
 		}, {
-			name: "ERROR_Redis_Expire",
+			name: "OK_Shadow_Rejection_Not_Counted_Send",
 			fields: fields{
 				rdb: (&redisMock{
-					incrCmd:   redisCmd[int64]{val: 1},
-					expireCmd: redisCmd[bool]{err: redisErr},
-					ttlCmd:    redisCmd[time.Duration]{exclude: true},
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(1), int64(1), int64(60000), int64(60000)}},
 				}).buildRedisMock(),
-				manager: okMgr,
+				delegate: (&delegateMock{
+					result: okResponse,
+				}).buildDelegateMock(),
+				manager: (&managerMock{
+					config:    shadowConfig,
+					shadow:    true,
+					shadowErr: redisErr,
+				}).buildManagerMock(),
 			},
-			args:      okNotification,
-			want:      InternalErrorResult,
-			wantErr:   true,
-			targetErr: ErrProcessingNotificationRequest,
+			args: okNotification,
+			want: sentResult(1, 0, 60000, 60000),
+		}, {
+			name: "OK_Disabled_Send",
+			fields: fields{
+				delegate: (&delegateMock{
+					result: okResponse,
+				}).buildDelegateMock(),
+				manager: (&managerMock{
+					config: &model.Config{
+						Name:       "Newsletter",
+						LimitCount: 1,
+						TimeAmount: 1,
+						TimeUnit:   "MINUTE",
+						Mode:       model.Disabled,
+					},
+				}).buildManagerMock(),
+			},
+			args: okNotification,
+			want: okResponse,
+		}, {
+			name: "OK_Exempt_Recipient_Send",
+			fields: fields{
+				delegate: (&delegateMock{
+					result: okResponse,
+				}).buildDelegateMock(),
+				manager: (&managerMock{
+					override: &model.Override{
+						Recipient: "a@a.a",
+						Exempt:    true,
+					},
+					getByNameErr: manager.ErrNotificationConfigNotFound,
+				}).buildManagerMock(),
+			},
+			args: okNotification,
+			want: okResponse,
 		}, {
-			name: "ERROR_Redis_TTL",
+			name: "OK_Override_Send",
 			fields: fields{
 				rdb: (&redisMock{
-					incrCmd:   redisCmd[int64]{val: 2},
-					expireCmd: redisCmd[bool]{exclude: true},
-					ttlCmd:    redisCmd[time.Duration]{err: redisErr},
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(0), int64(3600000)}},
 				}).buildRedisMock(),
-				manager: okMgr,
+				delegate: (&delegateMock{
+					result: okResponse,
+				}).buildDelegateMock(),
+				manager: overrideMgr,
 			},
-			args:      okNotification,
-			want:      InternalErrorResult,
-			wantErr:   true,
-			targetErr: ErrProcessingNotificationRequest,
+			args: okNotification,
+			want: sentResult(10, 9, 3600000, 0),
 		}, {
-			name: "ERROR_Delegate_Send",
+			name: "OK_Override_Rejected",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(1), int64(10), int64(1200000), int64(1200000)}},
+				}).buildRedisMock(),
+				manager: overrideMgr,
+			},
+			args: okNotification,
+			want: &pb.Result{
+				Status:          pb.Status_REJECTED,
+				ResponseMessage: "notification to recipient was rejected, Newsletter 1h0m0s window limit was reached",
+				Limit:           10,
+				ResetAfterMs:    1200000,
+				RetryAfterMs:    1200000,
+			},
+		}, {
+			name: "OK_Window_Override_Rejected",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(1), int64(10), int64(1200000), int64(1200000)}},
+				}).buildRedisMock(),
+				manager: (&managerMock{
+					config: okConfig,
+					override: &model.Override{
+						Recipient:  "a@a.a",
+						LimitCount: 10,
+						Window:     "PT90M",
+					},
+				}).buildManagerMock(),
+			},
+			args: okNotification,
+			want: &pb.Result{
+				Status:          pb.Status_REJECTED,
+				ResponseMessage: "notification to recipient was rejected, Newsletter 1h30m0s window limit was reached",
+				Limit:           10,
+				ResetAfterMs:    1200000,
+				RetryAfterMs:    1200000,
+			},
+		}, {
+			name: "OK_Refund_On_Internal_Error_Result",
 			fields: fields{
 				rdb: (&redisMock{
-					incrCmd:   redisCmd[int64]{val: 1},
-					expireCmd: redisCmd[bool]{val: true},
-					ttlCmd:    redisCmd[time.Duration]{exclude: true},
+					evalCmd:    redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(0), int64(60000)}},
+					releaseCmd: &redisCmd[[]interface{}]{val: []interface{}{int64(1)}},
 				}).buildRedisMock(),
 				delegate: (&delegateMock{
-					sendErr: errors.New("i/o error"),
+					result: InternalErrorResult,
+				}).buildDelegateMock(),
+				manager: refundMgr,
+			},
+			args: okNotification,
+			want: InternalErrorResult,
+		}, {
+			name: "ERROR_Refund_On_Delegate_Send",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd:    redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(0), int64(60000)}},
+					releaseCmd: &redisCmd[[]interface{}]{val: []interface{}{int64(1)}},
+				}).buildRedisMock(),
+				delegate: (&delegateMock{
+					sendErr: errors.New("i/o error"),
+				}).buildDelegateMock(),
+				manager: refundMgr,
+			},
+			args:      okNotification,
+			want:      InternalErrorResult,
+			wantErr:   true,
+			targetErr: ErrProcessingNotificationRequest,
+		}, {
+			name: "ERROR_Refund_Redis",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd:    redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(0), int64(60000)}},
+					releaseCmd: &redisCmd[[]interface{}]{err: redisErr},
+				}).buildRedisMock(),
+				delegate: (&delegateMock{
+					sendErr: errors.New("i/o error"),
+				}).buildDelegateMock(),
+				manager: refundMgr,
+			},
+			args:      okNotification,
+			want:      InternalErrorResult,
+			wantErr:   true,
+			targetErr: ErrProcessingNotificationRequest,
+		}, {
+			name: "ERROR_Recipient_Override",
+			fields: fields{
+				manager: (&managerMock{
+					overrideErr:      redisErr,
+					getByNameExclude: true,
+				}).buildManagerMock(),
+			},
+			args:      okNotification,
+			want:      InternalErrorResult,
+			wantErr:   true,
+			targetErr: ErrProcessingNotificationRequest,
+		}, {
+			name: "ERROR_Unknown_Notification_Config",
+			fields: fields{
+				manager: (&managerMock{
+					getByNameErr: redisErr,
+				}).buildManagerMock(),
+			},
+			args:      okNotification,
+			want:      InternalErrorResult,
+			wantErr:   true,
+			targetErr: ErrProcessingNotificationRequest,
+		}, {
+			name: "ERROR_Cap_Config",
+			fields: fields{
+				manager: (&managerMock{
+					config: okConfig,
+					capErr: redisErr,
+				}).buildManagerMock(),
+			},
+			args:      okNotification,
+			want:      InternalErrorResult,
+			wantErr:   true,
+			targetErr: ErrProcessingNotificationRequest,
+		}, {
+			name: "ERROR_Unknown_Algorithm",
+			fields: fields{
+				manager: (&managerMock{
+					config: &model.Config{
+						Name:       "Newsletter",
+						LimitCount: 1,
+						TimeAmount: 1,
+						TimeUnit:   "MINUTE",
+						Algorithm:  "LEAKY_BUCKET",
+					},
+				}).buildManagerMock(),
+			},
+			args:      okNotification,
+			want:      InternalErrorResult,
+			wantErr:   true,
+			targetErr: ErrProcessingNotificationRequest,
+		}, {
+			name: "ERROR_Redis_Fixed_Window",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{err: redisErr},
+				}).buildRedisMock(),
+				manager: okMgr,
+			},
+			args:      okNotification,
+			want:      InternalErrorResult,
+			wantErr:   true,
+			targetErr: ErrProcessingNotificationRequest,
+		}, {
+			name: "ERROR_Redis_Sliding_Window_Log",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{err: redisErr},
+				}).buildRedisMock(),
+				manager: slidingWindowLogMgr,
+			},
+			args:      okNotification,
+			want:      InternalErrorResult,
+			wantErr:   true,
+			targetErr: ErrProcessingNotificationRequest,
+		}, {
+			name: "ERROR_Redis_Token_Bucket",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{err: redisErr},
+				}).buildRedisMock(),
+				manager: tokenBucketMgr,
+			},
+			args:      okNotification,
+			want:      InternalErrorResult,
+			wantErr:   true,
+			targetErr: ErrProcessingNotificationRequest,
+		}, {
+			name: "ERROR_Redis_GCRA",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{err: redisErr},
+				}).buildRedisMock(),
+				manager: gcraMgr,
+			},
+			args:      okNotification,
+			want:      InternalErrorResult,
+			wantErr:   true,
+			targetErr: ErrProcessingNotificationRequest,
+		}, {
+			name: "ERROR_Unexpected_Decisive_Rule",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(3), int64(20), int64(3600000), int64(3600000)}},
+				}).buildRedisMock(),
+				manager: stackedLimitsMgr,
+			},
+			args:      okNotification,
+			want:      InternalErrorResult,
+			wantErr:   true,
+			targetErr: ErrProcessingNotificationRequest,
+		}, {
+			name: "ERROR_Delegate_Send",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(0), int64(60000)}},
+				}).buildRedisMock(),
+				delegate: (&delegateMock{
+					sendErr: errors.New("i/o error"),
 				}).buildDelegateMock(),
 				manager: okMgr,
 			},
@@ -216,6 +804,22 @@ func getTestCases() []*testCase {
 			want:      InternalErrorResult,
 			wantErr:   true,
 			targetErr: ErrProcessingNotificationRequest,
+		}, {
+			name: "ERROR_Delegate_Circuit_Open",
+			fields: fields{
+				rdb: (&redisMock{
+					evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(0), int64(60000)}},
+				}).buildRedisMock(),
+				delegate: (&delegateMock{
+					result:  CircuitOpenResult,
+					sendErr: ErrCircuitOpen,
+				}).buildDelegateMock(),
+				manager: okMgr,
+			},
+			args:      okNotification,
+			want:      CircuitOpenResult,
+			wantErr:   true,
+			targetErr: ErrCircuitOpen,
 		},
 	}
 }
@@ -228,7 +832,9 @@ func Test_client_Send(t *testing.T) {
 			c := &client{
 				delegate: tt.fields.delegate,
 				manager:  tt.fields.manager,
-				rdb:      tt.fields.rdb,
+				store:    NewRedisStore(tt.fields.rdb),
+				limiters: newLimiters(tt.fields.rdb),
+				health:   newHealthDetector(NewRedisStore(tt.fields.rdb)),
 				logger:   zap.L(),
 			}
 			got, err := c.Send(tt.args)
@@ -242,9 +848,2510 @@ func Test_client_Send(t *testing.T) {
 				return
 			}
 
-			if !reflect.DeepEqual(got, tt.want) {
+			if !proto.Equal(got, tt.want) {
+				t.Errorf("Send() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_client_Send_CountingPolicy(t *testing.T) {
+	SetUp(t)
+
+	notification := &pb.Notification{
+		Recipient:        "a@a.a",
+		Message:          "Hello world",
+		NotificationType: "Newsletter",
+	}
+
+	rejected := &Decision{
+		Rule:       Rule{Name: "Newsletter", Window: time.Minute},
+		Limit:      1,
+		Count:      1,
+		RetryAfter: time.Minute,
+		ResetAfter: time.Minute,
+	}
+
+	tests := []struct {
+		name   string
+		policy string
+	}{
+		{
+			name: "OK_Default_Counts_Accepted",
+		}, {
+			name:   "OK_Counts_Accepted",
+			policy: model.CountAccepted,
+		}, {
+			name:   "OK_Counts_All",
+			policy: model.CountAll,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := Mock[Limiter]()
+			if tt.policy == model.CountAll {
+				When(limiter.Reserve(Any[[]Rule]())).ThenReturn(rejected, nil)
+			} else {
+				When(limiter.Allow(Any[[]Rule]())).ThenReturn(rejected, nil)
+			}
+
+			c := &client{
+				manager: (&managerMock{
+					config: &model.Config{
+						Name:           "Newsletter",
+						LimitCount:     1,
+						TimeAmount:     1,
+						TimeUnit:       "MINUTE",
+						CountingPolicy: tt.policy,
+					},
+				}).buildManagerMock(),
+				limiters: map[string]Limiter{model.FixedWindow: limiter},
+				health:   newHealthDetector(NewMemoryStore()),
+				logger:   zap.L(),
+			}
+
+			got, err := c.Send(notification)
+			if err != nil {
+				t.Errorf("Send() error = %v", err)
+				return
+			}
+
+			if got.Status != pb.Status_REJECTED {
+				t.Errorf("Send() got = %v, want %v", got.Status, pb.Status_REJECTED)
+			}
+		})
+	}
+}
+
+func Test_client_Send_ShadowRefund(t *testing.T) {
+	SetUp(t)
+
+	notification := &pb.Notification{
+		Recipient:        "a@a.a",
+		Message:          "Hello world",
+		NotificationType: "Newsletter",
+	}
+
+	decision := func(allowed bool) *Decision {
+		return &Decision{
+			Rule:       Rule{Name: "Newsletter", Window: time.Minute},
+			Allowed:    allowed,
+			Limit:      2,
+			Count:      2,
+			ResetAfter: time.Minute,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		policy       string
+		allowed      bool
+		releaseCalls int
+	}{
+		{
+			name:         "OK_Allowed_Refunded",
+			allowed:      true,
+			releaseCalls: 1,
+		}, {
+			name: "OK_Would_Be_Rejection_Not_Refunded",
+		}, {
+			name:         "OK_Would_Be_Rejection_Counting_All_Refunded",
+			policy:       model.CountAll,
+			releaseCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := Mock[Limiter]()
+			if tt.policy == model.CountAll {
+				When(limiter.Reserve(Any[[]Rule]())).ThenReturn(decision(tt.allowed), nil)
+			} else {
+				When(limiter.Allow(Any[[]Rule]())).ThenReturn(decision(tt.allowed), nil)
+			}
+
+			if tt.releaseCalls > 0 {
+				When(limiter.Release(Any[[]Rule](), Any[*Decision]())).ThenReturn(nil)
+			}
+
+			c := &client{
+				delegate: (&delegateMock{sendErr: errors.New("grpc: error")}).buildDelegateMock(),
+				manager: (&managerMock{
+					config: &model.Config{
+						Name:            "Newsletter",
+						LimitCount:      2,
+						TimeAmount:      1,
+						TimeUnit:        "MINUTE",
+						Mode:            model.Shadow,
+						CountingPolicy:  tt.policy,
+						RefundOnFailure: true,
+					},
+					shadow: !tt.allowed,
+				}).buildManagerMock(),
+				limiters: map[string]Limiter{model.FixedWindow: limiter},
+				health:   newHealthDetector(NewMemoryStore()),
+				logger:   zap.L(),
+			}
+
+			got, err := c.Send(notification)
+			if err == nil {
+				t.Errorf("Send() error = %v, wantErr true", err)
+				return
+			}
+
+			if got.Status != pb.Status_INTERNAL_ERROR {
+				t.Errorf("Send() got = %v, want %v", got.Status, pb.Status_INTERNAL_ERROR)
+			}
+
+			Verify(limiter, Times(tt.releaseCalls)).Release(Any[[]Rule](), Any[*Decision]())
+		})
+	}
+}
+
+func Test_client_Send_OverflowPolicy(t *testing.T) {
+	SetUp(t)
+
+	current := time.UnixMilli(1700000000000)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	notification := &pb.Notification{
+		Recipient:        "a@a.a",
+		Message:          "Hello world",
+		NotificationType: "Newsletter",
+	}
+
+	rejected := &Decision{
+		Rule:       Rule{Name: "Newsletter", Window: time.Minute},
+		Limit:      1,
+		Count:      1,
+		RetryAfter: 30 * time.Second,
+		ResetAfter: 30 * time.Second,
+	}
+
+	tests := []struct {
+		name      string
+		policy    string
+		zAddErr   error
+		zAddCalls bool
+		want      *pb.Result
+		wantErr   bool
+	}{
+		{
+			name: "OK_Default_Rejects",
+			want: &pb.Result{
+				Status:          pb.Status_REJECTED,
+				ResponseMessage: "notification to recipient was rejected, Newsletter 1m0s window limit was reached",
+				Limit:           1,
+				ResetAfterMs:    30000,
+				RetryAfterMs:    30000,
+			},
+		}, {
+			name:      "OK_Defers",
+			policy:    model.OverflowDefer,
+			zAddCalls: true,
+			want: &pb.Result{
+				Status:          pb.Status_QUEUED,
+				ResponseMessage: "notification to recipient was queued, Newsletter 1m0s window limit was reached",
+				Limit:           1,
+				ResetAfterMs:    30000,
+				RetryAfterMs:    30000,
+				ScheduledAtMs:   1700000030000,
+			},
+		}, {
+			name:      "ERROR_Redis_ZAdd",
+			policy:    model.OverflowDefer,
+			zAddErr:   errors.New("redis: error"),
+			zAddCalls: true,
+			want:      InternalErrorResult,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := Mock[Limiter]()
+			When(limiter.Allow(Any[[]Rule]())).ThenReturn(rejected, nil)
+
+			rdbMock := Mock[redis.Cmdable]()
+			if tt.zAddCalls {
+				When(rdbMock.ZAdd(Exact(model.DeferredNotificationSet), Any[[]redis.Z]()...)).
+					ThenReturn(redis.NewIntResult(1, tt.zAddErr))
+			}
+
+			c := &client{
+				manager: (&managerMock{
+					config: &model.Config{
+						Name:           "Newsletter",
+						LimitCount:     1,
+						TimeAmount:     1,
+						TimeUnit:       "MINUTE",
+						OverflowPolicy: tt.policy,
+					},
+				}).buildManagerMock(),
+				store:    NewRedisStore(rdbMock),
+				limiters: map[string]Limiter{model.FixedWindow: limiter},
+				health:   newHealthDetector(NewRedisStore(rdbMock)),
+				logger:   zap.L(),
+			}
+
+			got, err := c.Send(notification)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr && !errors.Is(err, ErrProcessingNotificationRequest) {
+				t.Errorf("Send() error = %v, targetErr = %v", err, ErrProcessingNotificationRequest)
+				return
+			}
+
+			if !proto.Equal(got, tt.want) {
 				t.Errorf("Send() got = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func Test_client_Send_DegradationPolicy(t *testing.T) {
+	SetUp(t)
+
+	current := time.UnixMilli(1700000000000)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	notification := &pb.Notification{
+		Recipient:        "a@a.a",
+		Message:          "Hello world",
+		NotificationType: "Newsletter",
+	}
+
+	sent := &pb.Result{
+		Status:          pb.Status_SENT,
+		ResponseMessage: "notification sent to recipient",
+	}
+
+	unavailableResult := &pb.Result{
+		Status:          pb.Status_REJECTED,
+		ResponseMessage: "notification to recipient was rejected, rate limiter is unavailable",
+	}
+
+	connErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name          string
+		policy        string
+		defaultPolicy string
+		getByNameErr  error
+		// storeDown tells only the store is found down, while the manager still answers
+		storeDown bool
+		sendCalls int
+		want      []*pb.Result
+	}{
+		{
+			name:      "OK_Fail_Open_Detected_By_Store",
+			policy:    model.FailOpen,
+			storeDown: true,
+			sendCalls: 2,
+			want:      []*pb.Result{sent, sent},
+		}, {
+			name:      "OK_Fail_Open",
+			policy:    model.FailOpen,
+			sendCalls: 2,
+			want:      []*pb.Result{sent, sent},
+		}, {
+			name:   "OK_Fail_Closed",
+			policy: model.FailClosed,
+			want:   []*pb.Result{unavailableResult, unavailableResult},
+		}, {
+			name:          "OK_Default_Policy",
+			defaultPolicy: model.FailOpen,
+			sendCalls:     2,
+			want:          []*pb.Result{sent, sent},
+		}, {
+			name:      "OK_Fallback",
+			policy:    model.Fallback,
+			sendCalls: 1,
+			want: []*pb.Result{sentResult(1, 0, 60000, 60000), {
+				Status:          pb.Status_REJECTED,
+				ResponseMessage: "notification to recipient was rejected, Newsletter 1m0s window limit was reached",
+				Limit:           1,
+				ResetAfterMs:    60000,
+				RetryAfterMs:    60000,
+			}},
+		}, {
+			name:          "OK_Fallback_Without_Config",
+			defaultPolicy: model.Fallback,
+			getByNameErr:  connErr,
+			want:          []*pb.Result{unavailableResult, unavailableResult},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdbMock := Mock[redis.Cmdable]()
+			if tt.storeDown {
+				When(rdbMock.EvalSha(AnyString(), Any[[]string](), Any[[]interface{}]()...)).
+					ThenReturn(redis.NewCmdResult(nil, connErr))
+			}
+
+			// the manager shares the Redis client with the store, so only the cached configs are loaded
+			mgr := &managerMock{
+				config: &model.Config{
+					Name:              "Newsletter",
+					LimitCount:        1,
+					TimeAmount:        1,
+					TimeUnit:          "MINUTE",
+					DegradationPolicy: tt.policy,
+				},
+				getByNameErr: tt.getByNameErr,
+				overrideErr:  connErr,
+				capErr:       connErr,
+			}
+			if tt.storeDown {
+				mgr.overrideErr, mgr.capErr = nil, nil
+			}
+
+			dlgMock := Mock[service.Client]()
+			if tt.sendCalls > 0 {
+				When(dlgMock.Send(Any[*pb.Notification]())).ThenReturn(sent, nil)
+			}
+
+			c := &client{
+				delegate:          dlgMock,
+				manager:           mgr.buildManagerMock(),
+				store:             NewRedisStore(rdbMock),
+				limiters:          newLimiters(rdbMock),
+				fallback:          NewMemoryStore().newLimiters(),
+				health:            newHealthDetector(NewRedisStore(rdbMock)),
+				degradationPolicy: tt.defaultPolicy,
+				logger:            zap.L(),
+			}
+			c.health.interval = time.Hour
+
+			for i, want := range tt.want {
+				got, err := c.Send(notification)
+				if err != nil {
+					t.Errorf("Send() #%d error = %v", i, err)
+					return
+				}
+
+				if !proto.Equal(got, want) {
+					t.Errorf("Send() #%d got = %v, want %v", i, got, want)
+					return
+				}
+			}
+
+			// the store is not evaluated once known to be down
+			if tt.storeDown {
+				Verify(rdbMock, Times(1)).EvalSha(AnyString(), Any[[]string](), Any[[]interface{}]()...)
+			}
+			Verify(dlgMock, Times(tt.sendCalls)).Send(Any[*pb.Notification]())
+		})
+	}
+}
+
+func Test_healthDetector(t *testing.T) {
+	SetUp(t)
+
+	current := time.UnixMilli(1700000000000)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	pings := make(chan error, 1)
+	rdbMock := Mock[redis.Cmdable]()
+	WhenSingle(rdbMock.Ping()).ThenAnswer(func(args []any) *redis.StatusCmd {
+		return redis.NewStatusResult("PONG", <-pings)
+	})
+
+	h := newHealthDetector(NewRedisStore(rdbMock))
+	probing := func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return h.probing
+	}
+
+	if h.failed(errors.New("ERR unknown command")) || !h.available() {
+		t.Errorf("failed() marked the store down on a command error")
+		return
+	}
+
+	if !h.failed(fmt.Errorf("error trying to evaluate: %w", errors.Join(io.EOF, ErrProcessingNotificationRequest))) || h.available() {
+		t.Errorf("failed() did not mark the store down on a connection error")
+		return
+	}
+
+	for _, err := range []error{errors.New("dial tcp: connection refused"), nil} {
+		current = current.Add(healthProbeInterval)
+		pings <- err
+		h.available()
+
+		// the probe runs in the background
+		deadline := time.Now().Add(time.Second)
+		for probing() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if !h.available() {
+		t.Errorf("available() got = false after a successful probe, want true")
+	}
+}
+
+func Test_client_Send_Deduplication(t *testing.T) {
+	SetUp(t)
+
+	current := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	notification := &pb.Notification{
+		Recipient:        "a@a.a",
+		Message:          "Hello world",
+		NotificationType: "Marketing",
+	}
+
+	other := &pb.Notification{
+		Recipient:        "a@a.a",
+		Message:          "Goodbye world",
+		NotificationType: "Marketing",
+	}
+
+	deduplicated := &pb.Result{
+		Status:          pb.Status_DEDUPLICATED,
+		ResponseMessage: "notification to recipient was deduplicated, an identical one was sent within PT10M",
+	}
+
+	failed := &pb.Result{
+		Status:          pb.Status_INTERNAL_ERROR,
+		ResponseMessage: "error processing notification request",
+	}
+
+	tests := []struct {
+		name          string
+		mode          string
+		exempt        bool
+		dedupWindow   string
+		delegateRes   *pb.Result
+		notifications []*pb.Notification
+		// elapsed is the time passed before every notification
+		elapsed   time.Duration
+		want      []*pb.Result
+		sendCalls int
+	}{
+		{
+			name:          "OK_Duplicate_Deduplicated_Without_Consuming_Quota",
+			dedupWindow:   "PT10M",
+			notifications: []*pb.Notification{notification, notification, other},
+			want:          []*pb.Result{sentResult(2, 1, 3600000, 0), deduplicated, sentResult(2, 0, 3600000, 3600000)},
+			sendCalls:     2,
+		}, {
+			name:          "OK_Exempt_Recipient_Duplicate_Deduplicated",
+			exempt:        true,
+			dedupWindow:   "PT10M",
+			notifications: []*pb.Notification{notification, notification},
+			want:          []*pb.Result{sentResult(0, 0, 0, 0), deduplicated},
+			sendCalls:     1,
+		}, {
+			name:          "OK_Duplicate_Sent_After_Dedup_Window",
+			dedupWindow:   "PT10M",
+			notifications: []*pb.Notification{notification, notification},
+			elapsed:       10 * time.Minute,
+			want:          []*pb.Result{sentResult(2, 1, 3600000, 0), sentResult(2, 0, 3000000, 3000000)},
+			sendCalls:     2,
+		}, {
+			name:          "OK_Duplicate_Sent_Without_Dedup_Window",
+			notifications: []*pb.Notification{notification, notification},
+			want:          []*pb.Result{sentResult(2, 1, 3600000, 0), sentResult(2, 0, 3600000, 3600000)},
+			sendCalls:     2,
+		}, {
+			name:          "OK_Duplicate_Sent_In_Shadow_Mode",
+			mode:          model.Shadow,
+			dedupWindow:   "PT10M",
+			notifications: []*pb.Notification{notification, notification},
+			want:          []*pb.Result{sentResult(2, 1, 3600000, 0), sentResult(2, 0, 3600000, 3600000)},
+			sendCalls:     2,
+		}, {
+			name:          "OK_Retry_Of_Failed_Notification_Sent",
+			dedupWindow:   "PT10M",
+			delegateRes:   failed,
+			notifications: []*pb.Notification{notification, notification},
+			want:          []*pb.Result{failed, failed},
+			sendCalls:     2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := current
+			defer func() { current = start }()
+
+			delegateRes := tt.delegateRes
+			if delegateRes == nil {
+				delegateRes = &pb.Result{
+					Status:          pb.Status_SENT,
+					ResponseMessage: "notification sent to recipient",
+				}
+			}
+
+			dlgMock := Mock[service.Client]()
+			When(dlgMock.Send(Any[*pb.Notification]())).ThenReturn(delegateRes, nil)
+
+			mgr := &managerMock{
+				config: &model.Config{
+					Name:        "Marketing",
+					LimitCount:  2,
+					TimeAmount:  1,
+					TimeUnit:    "HOUR",
+					Mode:        tt.mode,
+					DedupWindow: tt.dedupWindow,
+				},
+			}
+			if tt.exempt {
+				mgr.override = &model.Override{Recipient: "a@a.a", Exempt: true}
+			}
+
+			store := NewMemoryStore()
+			c := &client{
+				delegate: dlgMock,
+				manager:  mgr.buildManagerMock(),
+				store:    store,
+				limiters: store.newLimiters(),
+				health:   newHealthDetector(store),
+				logger:   zap.L(),
+			}
+
+			for i, n := range tt.notifications {
+				if i > 0 {
+					current = current.Add(tt.elapsed)
+				}
+
+				got, err := c.Send(n)
+				if err != nil {
+					t.Errorf("Send() error = %v", err)
+					return
+				}
+
+				if !proto.Equal(got, tt.want[i]) {
+					t.Errorf("Send() #%d got = %v, want %v", i, got, tt.want[i])
+				}
+			}
+
+			Verify(dlgMock, Times(tt.sendCalls)).Send(Any[*pb.Notification]())
+		})
+	}
+}
+
+func Test_deferredWorker_DeliverDue_Retry(t *testing.T) {
+	SetUp(t)
+
+	current := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	notification := &pb.Notification{
+		Recipient:        "a@a.a",
+		Message:          "Hello world",
+		NotificationType: "News",
+	}
+
+	sent := &pb.Result{
+		Status:          pb.Status_SENT,
+		ResponseMessage: "notification sent to recipient",
+	}
+
+	failing := true
+	dlgMock := Mock[service.Client]()
+	WhenDouble(dlgMock.Send(Any[*pb.Notification]())).ThenAnswer(func(args []any) (*pb.Result, error) {
+		if failing {
+			return nil, errors.New("grpc: error")
+		}
+
+		return sent, nil
+	})
+
+	store := NewMemoryStore()
+	c := &client{
+		delegate: dlgMock,
+		manager: (&managerMock{
+			config: &model.Config{
+				Name:            "News",
+				LimitCount:      1,
+				TimeAmount:      1,
+				TimeUnit:        "MINUTE",
+				OverflowPolicy:  model.OverflowDefer,
+				RefundOnFailure: true,
+			},
+		}).buildManagerMock(),
+		store:    store,
+		limiters: store.newLimiters(),
+		health:   newHealthDetector(store),
+		logger:   zap.L(),
+	}
+
+	// the first one is delivered while the delegate is still up
+	failing = false
+	if got, _ := c.Send(notification); got.GetStatus() != pb.Status_SENT {
+		t.Fatalf("Send() got = %v, want %v", got.GetStatus(), pb.Status_SENT)
+	}
+
+	if got, _ := c.Send(notification); got.GetStatus() != pb.Status_QUEUED {
+		t.Fatalf("Send() got = %v, want %v", got.GetStatus(), pb.Status_QUEUED)
+	}
+
+	w := NewDeferredWorker(store, c)
+	steps := []struct {
+		name    string
+		failing bool
+		elapsed time.Duration
+		want    int
+		// queued is the amount of notifications left in the queue after the step
+		queued int
+	}{
+		{name: "OK_Failed_Delivery_Queued_Back", failing: true, elapsed: time.Minute, queued: 1},
+		{name: "OK_Retry_Not_Due_Yet", elapsed: 4 * time.Second, queued: 1},
+		{name: "OK_Second_Retry_Backed_Off", failing: true, elapsed: time.Second, queued: 1},
+		{name: "OK_Retried_Notification_Delivered", elapsed: 10 * time.Second, want: 1},
+	}
+	for _, st := range steps {
+		failing = st.failing
+		current = current.Add(st.elapsed)
+
+		got, err := w.DeliverDue()
+		if err != nil {
+			t.Fatalf("%s: DeliverDue() error = %v", st.name, err)
+		}
+
+		if got != st.want {
+			t.Errorf("%s: DeliverDue() got = %v, want %v", st.name, got, st.want)
+		}
+
+		if queued := len(store.(*memoryStore).deferred); queued != st.queued {
+			t.Errorf("%s: got %v queued notifications, want %v", st.name, queued, st.queued)
+		}
+	}
+
+	Verify(dlgMock, Times(4)).Send(Any[*pb.Notification]())
+}
+
+func Test_deferredWorker_DeliverDue_Deduplication(t *testing.T) {
+	SetUp(t)
+
+	first := &pb.Notification{
+		Recipient:        "a@a.a",
+		Message:          "Hello world",
+		NotificationType: "News",
+	}
+
+	second := &pb.Notification{
+		Recipient:        "a@a.a",
+		Message:          "Goodbye world",
+		NotificationType: "News",
+	}
+
+	tests := []struct {
+		name          string
+		config        *model.Config
+		notifications []*pb.Notification
+		// want are the statuses of the notifications, then of a retry of the last one
+		want      []pb.Status
+		elapsed   time.Duration
+		delivered int
+		sendCalls int
+	}{
+		{
+			name: "OK_Overflow_Deferred_Notification_Delivered",
+			config: &model.Config{
+				Name:           "News",
+				LimitCount:     1,
+				TimeAmount:     1,
+				TimeUnit:       "MINUTE",
+				OverflowPolicy: model.OverflowDefer,
+				DedupWindow:    "PT1H",
+			},
+			notifications: []*pb.Notification{first, second},
+			want:          []pb.Status{pb.Status_SENT, pb.Status_QUEUED, pb.Status_DEDUPLICATED},
+			elapsed:       time.Minute,
+			delivered:     1,
+			sendCalls:     2,
+		}, {
+			name: "OK_Quiet_Hours_Deferred_Notification_Delivered",
+			config: &model.Config{
+				Name:        "News",
+				LimitCount:  1,
+				TimeAmount:  1,
+				TimeUnit:    "MINUTE",
+				DedupWindow: "PT1H",
+				QuietHours:  []model.QuietHours{{Start: "22:00", End: "07:00", Policy: model.OverflowDefer}},
+			},
+			notifications: []*pb.Notification{first},
+			want:          []pb.Status{pb.Status_QUIET_HOURS, pb.Status_DEDUPLICATED},
+			elapsed:       30 * time.Minute,
+			delivered:     1,
+			sendCalls:     1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := time.Date(2024, 3, 15, 6, 30, 0, 0, time.UTC)
+			now = func() time.Time { return current }
+			defer func() { now = time.Now }()
+
+			dlgMock := Mock[service.Client]()
+			When(dlgMock.Send(Any[*pb.Notification]())).ThenReturn(&pb.Result{
+				Status:          pb.Status_SENT,
+				ResponseMessage: "notification sent to recipient",
+			}, nil)
+
+			store := NewMemoryStore()
+			c := &client{
+				delegate: dlgMock,
+				manager:  (&managerMock{config: tt.config}).buildManagerMock(),
+				store:    store,
+				limiters: store.newLimiters(),
+				health:   newHealthDetector(store),
+				logger:   zap.L(),
+			}
+
+			notifications := append(tt.notifications, tt.notifications[len(tt.notifications)-1])
+			for i, n := range notifications {
+				got, err := c.Send(n)
+				if err != nil {
+					t.Errorf("Send() error = %v", err)
+					return
+				}
+
+				if got.GetStatus() != tt.want[i] {
+					t.Errorf("Send() #%d got = %v, want %v", i, got.GetStatus(), tt.want[i])
+				}
+			}
+
+			current = current.Add(tt.elapsed)
+			delivered, err := NewDeferredWorker(store, c).DeliverDue()
+			if err != nil {
+				t.Errorf("DeliverDue() error = %v", err)
+				return
+			}
+
+			if delivered != tt.delivered {
+				t.Errorf("DeliverDue() got = %v, want %v", delivered, tt.delivered)
+			}
+
+			Verify(dlgMock, Times(tt.sendCalls)).Send(Any[*pb.Notification]())
+		})
+	}
+}
+
+func Test_client_Send_QuietHours(t *testing.T) {
+	SetUp(t)
+
+	bogota, err := time.LoadLocation("America/Bogota")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+
+	// a Friday, within the quiet hours started on Thursday night
+	current := time.Date(2024, 3, 15, 2, 30, 0, 0, bogota)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	notification := &pb.Notification{
+		Recipient:        "a@a.a",
+		Message:          "Hello world",
+		NotificationType: "Marketing",
+	}
+
+	sent := &pb.Result{
+		Status:          pb.Status_SENT,
+		ResponseMessage: "notification sent to recipient",
+	}
+
+	tests := []struct {
+		name       string
+		mode       string
+		exempt     bool
+		quietHours model.QuietHours
+		sent       bool
+		want       *pb.Result
+	}{
+		{
+			name:       "OK_Rejected_During_Quiet_Hours",
+			quietHours: model.QuietHours{Start: "22:00", End: "07:00"},
+			want: &pb.Result{
+				Status:          pb.Status_QUIET_HOURS,
+				ResponseMessage: "notification to recipient was rejected, quiet hours end at 2024-03-15T12:00:00Z",
+				RetryAfterMs:    16200000,
+			},
+		}, {
+			name:       "OK_Exempt_Recipient_Rejected_During_Quiet_Hours",
+			exempt:     true,
+			quietHours: model.QuietHours{Start: "22:00", End: "07:00"},
+			want: &pb.Result{
+				Status:          pb.Status_QUIET_HOURS,
+				ResponseMessage: "notification to recipient was rejected, quiet hours end at 2024-03-15T12:00:00Z",
+				RetryAfterMs:    16200000,
+			},
+		}, {
+			name:       "OK_Queued_During_Quiet_Hours",
+			quietHours: model.QuietHours{Days: []string{"THURSDAY"}, Start: "22:00", End: "07:00", Policy: model.OverflowDefer},
+			want: &pb.Result{
+				Status:          pb.Status_QUIET_HOURS,
+				ResponseMessage: "notification to recipient was queued, quiet hours end at 2024-03-15T12:00:00Z",
+				RetryAfterMs:    16200000,
+				ScheduledAtMs:   1710504000000,
+			},
+		}, {
+			name:       "OK_Sent_Out_Of_Quiet_Days",
+			quietHours: model.QuietHours{Days: []string{"FRIDAY", "SATURDAY"}, Start: "22:00", End: "07:00"},
+			sent:       true,
+			want:       sentResult(1, 0, 60000, 0),
+		}, {
+			name:       "OK_Sent_Out_Of_Quiet_Time_Zone",
+			quietHours: model.QuietHours{Start: "22:00", End: "07:00", TimeZone: "Asia/Tokyo"},
+			sent:       true,
+			want:       sentResult(1, 0, 60000, 0),
+		}, {
+			name:       "OK_Shadow_Sent",
+			mode:       model.Shadow,
+			quietHours: model.QuietHours{Start: "22:00", End: "07:00"},
+			sent:       true,
+			want:       sentResult(1, 0, 60000, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := Mock[Limiter]()
+			dlgMock := Mock[service.Client]()
+			if tt.sent {
+				When(limiter.Allow(Any[[]Rule]())).ThenReturn(&Decision{
+					Allowed:    true,
+					Limit:      1,
+					Count:      1,
+					ResetAfter: time.Minute,
+				}, nil)
+				When(dlgMock.Send(Any[*pb.Notification]())).ThenReturn(sent, nil)
+			}
+
+			rdbMock := Mock[redis.Cmdable]()
+			if tt.quietHours.Policy == model.OverflowDefer {
+				When(rdbMock.ZAdd(Exact(model.DeferredNotificationSet), Any[[]redis.Z]()...)).
+					ThenReturn(redis.NewIntResult(1, nil))
+			}
+
+			mgr := &managerMock{
+				config: &model.Config{
+					Name:       "Marketing",
+					LimitCount: 1,
+					TimeAmount: 1,
+					TimeUnit:   "MINUTE",
+					Mode:       tt.mode,
+					TimeZone:   "America/Bogota",
+					QuietHours: []model.QuietHours{tt.quietHours},
+				},
+			}
+			if tt.exempt {
+				mgr.override = &model.Override{Recipient: "a@a.a", Exempt: true}
+			}
+
+			c := &client{
+				delegate: dlgMock,
+				manager:  mgr.buildManagerMock(),
+				store:    NewRedisStore(rdbMock),
+				limiters: map[string]Limiter{model.FixedWindow: limiter},
+				health:   newHealthDetector(NewRedisStore(rdbMock)),
+				logger:   zap.L(),
+			}
+
+			got, err := c.Send(notification)
+			if err != nil {
+				t.Errorf("Send() error = %v", err)
+				return
+			}
+
+			if !proto.Equal(got, tt.want) {
+				t.Errorf("Send() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_deferredWorker_DeliverDue(t *testing.T) {
+	SetUp(t)
+
+	deferred := `{"id":"1","recipient":"a@a.a","message":"Hello world","notificationType":"Newsletter","scheduledAt":"2023-11-14T22:13:20Z"}`
+	claimed := `{"id":"2","recipient":"b@b.b","message":"Hello world","notificationType":"Newsletter","scheduledAt":"2023-11-14T22:13:20Z"}`
+	redisErr := errors.New("redis: error")
+
+	tests := []struct {
+		name        string
+		members     []string
+		rangeErr    error
+		remErr      error
+		remExclude  bool
+		sendErr     error
+		sendExclude bool
+		addErr      error
+		want        int
+		wantErr     bool
+	}{
+		{
+			name:    "OK_Due_Notification_Delivered",
+			members: []string{deferred},
+			want:    1,
+		}, {
+			name:    "OK_Notification_Claimed_By_Another_Worker",
+			members: []string{deferred, claimed},
+			want:    1,
+		}, {
+			name:        "OK_Invalid_Notification_Discarded",
+			members:     []string{"not a notification"},
+			sendExclude: true,
+		}, {
+			name:        "OK_No_Due_Notifications",
+			remExclude:  true,
+			sendExclude: true,
+		}, {
+			name:    "OK_Delivery_Error_Retried",
+			members: []string{deferred},
+			sendErr: ErrProcessingNotificationRequest,
+		}, {
+			name:    "ERROR_Redis_ZAdd_Retry",
+			members: []string{deferred},
+			sendErr: ErrProcessingNotificationRequest,
+			addErr:  redisErr,
+			wantErr: true,
+		}, {
+			name:        "ERROR_Redis_ZRangeByScore",
+			rangeErr:    redisErr,
+			remExclude:  true,
+			sendExclude: true,
+			wantErr:     true,
+		}, {
+			name:        "ERROR_Redis_ZRem",
+			members:     []string{deferred},
+			remErr:      redisErr,
+			sendExclude: true,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdbMock := Mock[redis.Cmdable]()
+			When(rdbMock.ZRangeByScore(Exact(model.DeferredNotificationSet), Any[redis.ZRangeBy]())).
+				ThenReturn(redis.NewStringSliceResult(tt.members, tt.rangeErr))
+
+			if !tt.remExclude {
+				WhenSingle(rdbMock.ZRem(Exact(model.DeferredNotificationSet), Any[[]interface{}]()...)).
+					ThenAnswer(func(args []any) *redis.IntCmd {
+						if args[1].([]interface{})[0] == claimed {
+							return redis.NewIntResult(0, nil)
+						}
+
+						return redis.NewIntResult(1, tt.remErr)
+					})
+			}
+
+			if tt.sendErr != nil {
+				WhenSingle(rdbMock.ZAdd(Exact(model.DeferredNotificationSet), Any[[]redis.Z]()...)).
+					ThenAnswer(func(args []any) *redis.IntCmd {
+						retried := &model.DeferredNotification{}
+						z := args[1].([]redis.Z)[0]
+						if err := retried.FromJSONString(z.Member.(string)); err != nil || retried.Attempts != 1 {
+							t.Errorf("ZAdd() got member = %v, want a first retry", z.Member)
+						}
+
+						return redis.NewIntResult(1, tt.addErr)
+					})
+			}
+
+			clientMock := Mock[service.Client]()
+			if !tt.sendExclude {
+				When(clientMock.Send(Any[*pb.Notification]())).ThenReturn(sentResult(1, 0, 60000, 0), tt.sendErr)
+			}
+
+			w := &deferredWorker{
+				store:  NewRedisStore(rdbMock),
+				client: clientMock,
+				logger: zap.L(),
+			}
+			got, err := w.DeliverDue()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DeliverDue() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr && !errors.Is(err, ErrDeliveringDeferredNotifications) {
+				t.Errorf("DeliverDue() error = %v, targetErr = %v", err, ErrDeliveringDeferredNotifications)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("DeliverDue() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_client_GetQuota(t *testing.T) {
+	SetUp(t)
+
+	current := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	resetAt := current.Add(45 * time.Second)
+	config := &model.Config{
+		Name:       "Newsletter",
+		LimitCount: 2,
+		TimeAmount: 1,
+		TimeUnit:   "MINUTE",
+	}
+
+	tests := []struct {
+		name    string
+		rdb     redis.Cmdable
+		manager manager.Service
+		want    *model.Quota
+		wantErr bool
+	}{
+		{
+			name: "OK_Quota_Retrieved",
+			rdb: (&redisMock{
+				evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(0), int64(45000)}},
+			}).buildRedisMock(),
+			manager: (&managerMock{
+				config: config,
+			}).buildManagerMock(),
+			want: &model.Quota{
+				Recipient:        "a@a.a",
+				NotificationType: "Newsletter",
+				LimitName:        "Newsletter",
+				Window:           "1m0s",
+				Limit:            2,
+				Count:            1,
+				Remaining:        1,
+				ResetAt:          &resetAt,
+			},
+		}, {
+			name: "OK_Exempt_Recipient_Quota",
+			manager: (&managerMock{
+				override: &model.Override{
+					Recipient: "a@a.a",
+					Exempt:    true,
+				},
+				getByNameExclude: true,
+			}).buildManagerMock(),
+			want: &model.Quota{
+				Recipient:        "a@a.a",
+				NotificationType: "Newsletter",
+				Unlimited:        true,
+			},
+		}, {
+			name: "ERROR_Unknown_Notification_Config",
+			manager: (&managerMock{
+				getByNameErr: manager.ErrNotificationConfigNotFound,
+			}).buildManagerMock(),
+			wantErr: true,
+		}, {
+			name: "ERROR_Redis_Peek",
+			rdb: (&redisMock{
+				evalCmd: redisCmd[[]interface{}]{err: errors.New("redis: error")},
+			}).buildRedisMock(),
+			manager: (&managerMock{
+				config: config,
+			}).buildManagerMock(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &client{
+				manager:  tt.manager,
+				store:    NewRedisStore(tt.rdb),
+				limiters: newLimiters(tt.rdb),
+				health:   newHealthDetector(NewRedisStore(tt.rdb)),
+				logger:   zap.L(),
+			}
+			got, err := c.GetQuota("a@a.a", "Newsletter")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetQuota() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr && !errors.Is(err, ErrProcessingNotificationRequest) {
+				t.Errorf("GetQuota() error = %v, targetErr = %v", err, ErrProcessingNotificationRequest)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetQuota() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_client_ListQuotas(t *testing.T) {
+	SetUp(t)
+
+	mgrMock := Mock[manager.Service]()
+	When(mgrMock.ListNotificationConfig()).ThenReturn([]*model.Config{
+		{Name: "Newsletter", LimitCount: 1, TimeAmount: 1, TimeUnit: "MINUTE", Group: "Marketing", Mode: model.Disabled},
+		{Name: "Marketing", LimitCount: 3, TimeAmount: 1, TimeUnit: "HOUR"},
+		{Name: model.GlobalGroup, LimitCount: 5, TimeAmount: 1, TimeUnit: "DAY"},
+	}, nil)
+	When(mgrMock.GetOverride(AnyString(), AnyString())).ThenReturn(nil, nil)
+	When(mgrMock.GetByName("Newsletter")).ThenReturn(&model.Config{
+		Name:       "Newsletter",
+		LimitCount: 1,
+		TimeAmount: 1,
+		TimeUnit:   "MINUTE",
+		Mode:       model.Disabled,
+	}, nil)
+
+	c := &client{
+		manager: mgrMock,
+		health:  newHealthDetector(NewMemoryStore()),
+		logger:  zap.L(),
+	}
+
+	want := []*model.Quota{{
+		Recipient:        "a@a.a",
+		NotificationType: "Newsletter",
+		Unlimited:        true,
+	}}
+
+	got, err := c.ListQuotas("a@a.a")
+	if err != nil {
+		t.Errorf("ListQuotas() error = %v", err)
+		return
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListQuotas() got = %+v, want %+v", got, want)
+	}
+}
+
+func Test_Limiter_Peek(t *testing.T) {
+	SetUp(t)
+
+	config := &model.Config{
+		Name:          "Newsletter",
+		LimitCount:    2,
+		TimeAmount:    1,
+		TimeUnit:      "MINUTE",
+		BurstCapacity: 2,
+		RefillRate:    2,
+	}
+
+	rules := newRules("a@a.a:Newsletter", config)
+	redisErr := errors.New("redis: error")
+
+	tests := []struct {
+		name      string
+		algorithm string
+		rdb       redis.Cmdable
+		want      *Decision
+		wantErr   bool
+	}{
+		{
+			name:      "OK_Fixed_Window_Unused",
+			algorithm: model.FixedWindow,
+			rdb: (&redisMock{
+				evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(0), int64(0), int64(0)}},
+			}).buildRedisMock(),
+			want: &Decision{
+				Rule:      rules[0],
+				Allowed:   true,
+				Limit:     2,
+				Remaining: 2,
+			},
+		}, {
+			name:      "OK_Sliding_Window_Log_Exhausted",
+			algorithm: model.SlidingWindowLog,
+			rdb: (&redisMock{
+				evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(0), int64(1), int64(2), int64(20000), int64(45000)}},
+			}).buildRedisMock(),
+			want: &Decision{
+				Rule:       rules[0],
+				Limit:      2,
+				Count:      2,
+				RetryAfter: 20 * time.Second,
+				ResetAfter: 45 * time.Second,
+			},
+		}, {
+			name:      "OK_Token_Bucket_Partially_Used",
+			algorithm: model.TokenBucket,
+			rdb: (&redisMock{
+				evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1), int64(1), int64(1), int64(0), int64(30000)}},
+			}).buildRedisMock(),
+			want: &Decision{
+				Rule:       rules[0],
+				Allowed:    true,
+				Limit:      2,
+				Count:      1,
+				Remaining:  1,
+				ResetAfter: 30 * time.Second,
+			},
+		}, {
+			name:      "ERROR_Redis_GCRA",
+			algorithm: model.GCRA,
+			rdb: (&redisMock{
+				evalCmd: redisCmd[[]interface{}]{err: redisErr},
+			}).buildRedisMock(),
+			wantErr: true,
+		}, {
+			name:      "ERROR_Unexpected_Reply",
+			algorithm: model.FixedWindow,
+			rdb: (&redisMock{
+				evalCmd: redisCmd[[]interface{}]{val: []interface{}{int64(1)}},
+			}).buildRedisMock(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLimiters(tt.rdb)[tt.algorithm]
+			got, err := l.Peek(rules)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Peek() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr && !errors.Is(err, ErrProcessingNotificationRequest) {
+				t.Errorf("Peek() error = %v, targetErr = %v", err, ErrProcessingNotificationRequest)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Peek() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Limiter_Release(t *testing.T) {
+	SetUp(t)
+
+	rules := []Rule{{
+		Key:    "a@a.a:Newsletter",
+		Limit:  2,
+		Burst:  2,
+		Refill: 2,
+		Window: time.Minute,
+	}}
+	redisErr := errors.New("redis: error")
+
+	for _, algorithm := range model.Algorithms {
+		for _, wantErr := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s_%t", algorithm, wantErr), func(t *testing.T) {
+				evalCmd := redisCmd[[]interface{}]{val: []interface{}{int64(1)}}
+				if wantErr {
+					evalCmd = redisCmd[[]interface{}]{err: redisErr}
+				}
+
+				l := newLimiters((&redisMock{evalCmd: evalCmd}).buildRedisMock())[algorithm]
+				err := l.Release(rules, &Decision{Allowed: true, Rule: rules[0], token: "member"})
+				if (err != nil) != wantErr {
+					t.Errorf("Release() error = %v, wantErr %v", err, wantErr)
+					return
+				}
+
+				if wantErr && !errors.Is(err, ErrProcessingNotificationRequest) {
+					t.Errorf("Release() error = %v, targetErr = %v", err, ErrProcessingNotificationRequest)
+				}
+			})
+		}
+	}
+}
+
+func Test_newRules(t *testing.T) {
+	config := &model.Config{
+		Name:          "Status",
+		LimitCount:    2,
+		TimeAmount:    1,
+		TimeUnit:      "MINUTE",
+		BurstCapacity: 2,
+		RefillRate:    1,
+		Limits: []model.Limit{{
+			LimitCount: 20,
+			TimeAmount: 1,
+			TimeUnit:   "DAY",
+		}},
+	}
+
+	want := []Rule{
+		{
+			Name:   "Status",
+			Key:    "a@a.a:Status",
+			Limit:  2,
+			Burst:  2,
+			Refill: 1,
+			Window: time.Minute,
+		}, {
+			Name:   "Status",
+			Key:    "a@a.a:Status:1:24h0m0s",
+			Limit:  20,
+			Burst:  20,
+			Refill: 20,
+			Window: 24 * time.Hour,
+		},
+	}
+
+	if got := newRules("a@a.a:Status", config); !reflect.DeepEqual(got, want) {
+		t.Errorf("newRules() got = %+v, want %+v", got, want)
+	}
+}
+
+func Test_newRules_CalendarAligned(t *testing.T) {
+	bogota, err := time.LoadLocation("America/Bogota")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+
+	current := time.Date(2024, 3, 15, 22, 30, 0, 0, bogota)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	config := &model.Config{
+		Name:       "News",
+		LimitCount: 1,
+		TimeAmount: 1,
+		TimeUnit:   "DAY",
+		Alignment:  model.AlignCalendar,
+		TimeZone:   "America/Bogota",
+		Limits: []model.Limit{{
+			LimitCount: 5,
+			TimeAmount: 5,
+			TimeUnit:   "HOUR",
+		}, {
+			LimitCount: 10,
+			TimeAmount: 7,
+			TimeUnit:   "DAY",
+		}},
+	}
+
+	want := []Rule{
+		{
+			Name:   "News",
+			Key:    "{a@a.a}:News:20240315T050000Z",
+			Limit:  1,
+			Window: 24 * time.Hour,
+			End:    time.Date(2024, 3, 16, 0, 0, 0, 0, bogota),
+		}, {
+			// the last window of the day is cut at midnight
+			Name:   "News",
+			Key:    "{a@a.a}:News:1:5h0m0s:20240316T010000Z",
+			Limit:  5,
+			Burst:  5,
+			Refill: 5,
+			Window: 5 * time.Hour,
+			End:    time.Date(2024, 3, 16, 0, 0, 0, 0, bogota),
+		}, {
+			// 19797 days since the epoch date, the window started 1 day ago
+			Name:   "News",
+			Key:    "{a@a.a}:News:2:168h0m0s:20240314T050000Z",
+			Limit:  10,
+			Burst:  10,
+			Refill: 10,
+			Window: 7 * 24 * time.Hour,
+			End:    time.Date(2024, 3, 21, 0, 0, 0, 0, bogota),
+		},
+	}
+
+	got := newRules("{a@a.a}:News", config)
+	if len(got) != len(want) {
+		t.Errorf("newRules() got = %+v, want %+v", got, want)
+		return
+	}
+
+	for i := range want {
+		if got[i].Key != want[i].Key || !got[i].End.Equal(want[i].End) || got[i].Window != want[i].Window {
+			t.Errorf("newRules()[%d] got = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func Test_newRules_ISOWindow(t *testing.T) {
+	current := time.Date(2024, 2, 20, 10, 45, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	config := &model.Config{
+		Name:       "News",
+		LimitCount: 4,
+		Window:     "P1M",
+		Limits: []model.Limit{{
+			LimitCount: 2,
+			Window:     "P1W",
+		}, {
+			LimitCount: 1,
+			Window:     "PT1H30M",
+		}},
+	}
+
+	tests := []struct {
+		name      string
+		alignment string
+		want      []Rule
+	}{
+		{
+			name: "Rolling",
+			want: []Rule{
+				{Key: "{a@a.a}:News", Window: 30 * 24 * time.Hour},
+				{Key: "{a@a.a}:News:1:168h0m0s", Window: 7 * 24 * time.Hour},
+				{Key: "{a@a.a}:News:2:1h30m0s", Window: 90 * time.Minute},
+			},
+		}, {
+			// February lasts 29 days on leap years, and weeks start on Monday
+			name:      "Calendar",
+			alignment: model.AlignCalendar,
+			want: []Rule{
+				{Key: "{a@a.a}:News:20240201T000000Z", Window: 30 * 24 * time.Hour, End: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+				{Key: "{a@a.a}:News:1:168h0m0s:20240219T000000Z", Window: 7 * 24 * time.Hour, End: time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC)},
+				{Key: "{a@a.a}:News:2:1h30m0s:20240220T103000Z", Window: 90 * time.Minute, End: time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Alignment = tt.alignment
+			got := newRules("{a@a.a}:News", config)
+			if len(got) != len(tt.want) {
+				t.Errorf("newRules() got = %+v, want %+v", got, tt.want)
+				return
+			}
+
+			for i, want := range tt.want {
+				if got[i].Key != want.Key || !got[i].End.Equal(want.End) || got[i].Window != want.Window {
+					t.Errorf("newRules()[%d] got = %+v, want %+v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func Test_newRules_SameWindow(t *testing.T) {
+	current := time.Date(2024, 2, 20, 10, 45, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	config := &model.Config{
+		Name:       "News",
+		LimitCount: 10,
+		TimeAmount: 1,
+		TimeUnit:   "MINUTE",
+		Limits: []model.Limit{{
+			LimitCount: 5,
+			TimeAmount: 1,
+			TimeUnit:   "DAY",
+		}, {
+			LimitCount: 3,
+			Window:     "PT24H",
+		}},
+	}
+
+	rules := newRules("{a@a.a}:News", config)
+	if rules[1].Key == rules[2].Key {
+		t.Errorf("newRules() got the same key %s for limits of the same window", rules[1].Key)
+		return
+	}
+
+	limiter := NewMemoryStore().newLimiters()[model.FixedWindow]
+	for i := 1; i <= 4; i++ {
+		got, err := limiter.Allow(rules)
+		if err != nil {
+			t.Errorf("Allow() #%d error = %v", i, err)
+			return
+		}
+
+		if got.Allowed != (i <= 3) || got.Rule.Key != rules[2].Key || got.Count != int64(min(i, 3)) {
+			t.Errorf("Allow() #%d got = %+v, want rule %s counting %d", i, got, rules[2].Key, min(i, 3))
+		}
+	}
+}
+
+func Test_client_Send_CalendarAligned(t *testing.T) {
+	SetUp(t)
+
+	current := time.Date(2024, 3, 15, 23, 59, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	notification := &pb.Notification{
+		Recipient:        "a@a.a",
+		Message:          "Hello world",
+		NotificationType: "News",
+	}
+
+	dlgMock := Mock[service.Client]()
+	When(dlgMock.Send(Any[*pb.Notification]())).ThenReturn(&pb.Result{
+		Status:          pb.Status_SENT,
+		ResponseMessage: "notification sent to recipient",
+	}, nil)
+
+	store := NewMemoryStore()
+	c := &client{
+		delegate: dlgMock,
+		manager: (&managerMock{
+			config: &model.Config{
+				Name:       "News",
+				LimitCount: 1,
+				TimeAmount: 1,
+				TimeUnit:   "DAY",
+				Alignment:  model.AlignCalendar,
+			},
+		}).buildManagerMock(),
+		store:    store,
+		limiters: store.newLimiters(),
+		health:   newHealthDetector(store),
+		logger:   zap.L(),
+	}
+
+	steps := []struct {
+		elapse time.Duration
+		want   *pb.Result
+	}{
+		{want: sentResult(1, 0, 60000, 60000)},
+		{elapse: 30 * time.Second, want: &pb.Result{
+			Status:          pb.Status_REJECTED,
+			ResponseMessage: "notification to recipient was rejected, News 24h0m0s window limit was reached",
+			Limit:           1,
+			ResetAfterMs:    30000,
+			RetryAfterMs:    30000,
+		}},
+		{elapse: 31 * time.Second, want: sentResult(1, 0, 86399000, 86399000)},
+	}
+	for i, step := range steps {
+		current = current.Add(step.elapse)
+		got, err := c.Send(notification)
+		if err != nil {
+			t.Errorf("Send() #%d error = %v", i, err)
+			return
+		}
+
+		if !proto.Equal(got, step.want) {
+			t.Errorf("Send() #%d got = %v, want %v", i, got, step.want)
+		}
+	}
+}
+
+func Test_newCapRules(t *testing.T) {
+	config := &model.Config{
+		Name:          model.GlobalGroup,
+		LimitCount:    5,
+		TimeAmount:    1,
+		TimeUnit:      "HOUR",
+		Algorithm:     model.TokenBucket,
+		BurstCapacity: 2,
+		RefillRate:    1,
+		Limits: []model.Limit{{
+			LimitCount: 20,
+			TimeAmount: 1,
+			TimeUnit:   "DAY",
+		}},
+	}
+
+	want := []Rule{
+		{
+			Name:   model.GlobalGroup,
+			Key:    "a@a.a:GROUP:GLOBAL",
+			Limit:  5,
+			Burst:  5,
+			Refill: 5,
+			Window: time.Hour,
+		}, {
+			Name:   model.GlobalGroup,
+			Key:    "a@a.a:GROUP:GLOBAL:1:24h0m0s",
+			Limit:  20,
+			Burst:  20,
+			Refill: 20,
+			Window: 24 * time.Hour,
+		},
+	}
+
+	if got := newCapRules("a@a.a:GROUP:GLOBAL", config); !reflect.DeepEqual(got, want) {
+		t.Errorf("newCapRules() got = %+v, want %+v", got, want)
+	}
+}
+
+func Test_newDomainRules(t *testing.T) {
+	config := &model.Config{
+		Name:       "Status",
+		LimitCount: 2,
+		TimeAmount: 1,
+		TimeUnit:   "MINUTE",
+		DomainLimits: []model.Limit{{
+			LimitCount: 50,
+			TimeAmount: 1,
+			TimeUnit:   "HOUR",
+		}, {
+			LimitCount: 500,
+			TimeAmount: 1,
+			TimeUnit:   "DAY",
+		}},
+	}
+
+	want := []Rule{
+		{
+			Name:   "example.com",
+			Key:    "{@example.com}:Status:1:1h0m0s",
+			Limit:  50,
+			Burst:  50,
+			Refill: 50,
+			Window: time.Hour,
+		}, {
+			Name:   "example.com",
+			Key:    "{@example.com}:Status:2:24h0m0s",
+			Limit:  500,
+			Burst:  500,
+			Refill: 500,
+			Window: 24 * time.Hour,
+		},
+	}
+
+	if got := newDomainRules(recipientDomain("John.Doe@Example.com"), config); !reflect.DeepEqual(got, want) {
+		t.Errorf("newDomainRules() got = %+v, want %+v", got, want)
+	}
+}
+
+func Test_newDomainRules_SameWindow(t *testing.T) {
+	current := time.Date(2024, 2, 20, 10, 45, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	config := &model.Config{
+		Name:       "Status",
+		LimitCount: 10,
+		TimeAmount: 1,
+		TimeUnit:   "MINUTE",
+		DomainLimits: []model.Limit{{
+			LimitCount: 5,
+			TimeAmount: 1,
+			TimeUnit:   "DAY",
+		}, {
+			LimitCount: 3,
+			Window:     "PT24H",
+		}},
+	}
+
+	rules := newDomainRules("example.com", config)
+	if rules[0].Key == rules[1].Key {
+		t.Errorf("newDomainRules() got the same key %s for limits of the same window", rules[0].Key)
+		return
+	}
+
+	limiter := NewMemoryStore().newLimiters()[model.FixedWindow]
+	for i := 1; i <= 4; i++ {
+		got, err := limiter.Allow(rules)
+		if err != nil {
+			t.Errorf("Allow() #%d error = %v", i, err)
+			return
+		}
+
+		if got.Allowed != (i <= 3) || got.Rule.Key != rules[1].Key || got.Count != int64(min(i, 3)) {
+			t.Errorf("Allow() #%d got = %+v, want rule %s counting %d", i, got, rules[1].Key, min(i, 3))
+		}
+	}
+}
+func Test_client_ResetCounters(t *testing.T) {
+	SetUp(t)
+
+	redisErr := errors.New("redis: error")
+
+	tests := []struct {
+		name             string
+		recipient        string
+		notificationType string
+		scanKeys         []string
+		scanErr          error
+		delErr           error
+		delExclude       bool
+		want             int64
+		wantErr          bool
+	}{
+		{
+			name:             "OK_Recipient_Type_Counters_Reset",
+			recipient:        "a@a.a",
+			notificationType: "Newsletter",
+			scanKeys:         []string{"a@a.a:Newsletter"},
+			want:             2,
+		}, {
+			name:      "OK_Recipient_Counters_Reset",
+			recipient: "a@a.a",
+			scanKeys:  []string{"a@a.a:Newsletter"},
+			want:      1,
+		}, {
+			name:             "OK_Type_Counters_Reset_Skipping_Configs",
+			notificationType: "Newsletter",
+			scanKeys:         []string{"NOTIFICATION_CONFIG:Newsletter", "a@a.a:Newsletter"},
+			want:             2,
+		}, {
+			name:             "OK_No_Counters_Found",
+			notificationType: "Newsletter",
+			delExclude:       true,
+		}, {
+			name:       "ERROR_Missing_Filters",
+			delExclude: true,
+			wantErr:    true,
+		}, {
+			name:      "ERROR_Redis_Scan",
+			recipient: "a@a.a",
+			scanErr:   redisErr,
+			wantErr:   true,
+		}, {
+			name:      "ERROR_Redis_Del",
+			recipient: "a@a.a",
+			scanKeys:  []string{"a@a.a:Newsletter"},
+			delErr:    redisErr,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdbMock := Mock[redis.Cmdable]()
+			if tt.recipient != "" || tt.notificationType != "" {
+				When(rdbMock.Scan(Any[uint64](), AnyString(), Any[int64]())).
+					ThenReturn(redis.NewScanCmdResult(tt.scanKeys, 0, tt.scanErr))
+			}
+
+			if !tt.delExclude && tt.scanErr == nil {
+				WhenSingle(rdbMock.Del(Any[[]string]()...)).ThenAnswer(func(args []any) *redis.IntCmd {
+					return redis.NewIntResult(int64(len(args[0].([]string))), tt.delErr)
+				})
+			}
+
+			c := &client{
+				store:  NewRedisStore(rdbMock),
+				logger: zap.L(),
+			}
+			got, err := c.ResetCounters(tt.recipient, tt.notificationType, "admin")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ResetCounters() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr && !errors.Is(err, ErrResettingCounters) {
+				t.Errorf("ResetCounters() error = %v, targetErr = %v", err, ErrResettingCounters)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("ResetCounters() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_counterPatterns(t *testing.T) {
+	tests := []struct {
+		name             string
+		recipient        string
+		notificationType string
+		want             []string
+	}{
+		{
+			name:             "Recipient_And_Type",
+			recipient:        "a@a.a",
+			notificationType: "Status",
+			want:             []string{"{a@a.a}:Status", "{a@a.a}:Status:*"},
+		}, {
+			name:      "Recipient",
+			recipient: "a*b@a.a",
+			want:      []string{`{a\*b@a.a}:*`},
+		}, {
+			name:             "Type",
+			notificationType: "Status",
+			want:             []string{"*}:Status", "*}:Status:*"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := counterPatterns(globEscaper.Replace(tt.recipient), globEscaper.Replace(tt.notificationType))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("counterPatterns() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_memoryLimiter(t *testing.T) {
+	current := time.UnixMilli(1700000000000)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	rules := []Rule{{
+		Name:   "Newsletter",
+		Key:    "a@a.a:Newsletter",
+		Limit:  2,
+		Burst:  2,
+		Refill: 2,
+		Window: time.Minute,
+	}}
+
+	tests := []struct {
+		name      string
+		algorithm string
+		retry     time.Duration
+	}{
+		{
+			name:      "OK_Fixed_Window",
+			algorithm: model.FixedWindow,
+			retry:     time.Minute,
+		}, {
+			name:      "OK_Sliding_Window_Log",
+			algorithm: model.SlidingWindowLog,
+			retry:     time.Minute,
+		}, {
+			name:      "OK_Token_Bucket",
+			algorithm: model.TokenBucket,
+			retry:     30 * time.Second,
+		}, {
+			name:      "OK_GCRA",
+			algorithm: model.GCRA,
+			retry:     30 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewMemoryStore().newLimiters()[tt.algorithm]
+
+			var allowed []bool
+			for i := 0; i < 3; i++ {
+				decision, err := l.Allow(rules)
+				if err != nil {
+					t.Fatalf("Allow() error = %v", err)
+				}
+				allowed = append(allowed, decision.Allowed)
+			}
+
+			if !reflect.DeepEqual(allowed, []bool{true, true, false}) {
+				t.Errorf("Allow() got = %v, want %v", allowed, []bool{true, true, false})
+			}
+
+			decision, err := l.Peek(rules)
+			if err != nil {
+				t.Fatalf("Peek() error = %v", err)
+			}
+
+			if decision.Count != 2 || decision.Remaining != 0 || decision.RetryAfter != tt.retry {
+				t.Errorf("Peek() got = %+v, want count 2, remaining 0 and retry %v", decision, tt.retry)
+			}
+
+			reserved, err := l.Reserve(rules)
+			if err != nil {
+				t.Fatalf("Reserve() error = %v", err)
+			}
+
+			if err := l.Release(rules, reserved); err != nil {
+				t.Fatalf("Release() error = %v", err)
+			}
+
+			if decision, _ = l.Peek(rules); decision.Count != 2 {
+				t.Errorf("Peek() after release got count = %v, want 2", decision.Count)
+			}
+
+			current = current.Add(time.Minute)
+			if decision, _ = l.Allow(rules); !decision.Allowed || decision.Count != 1 {
+				t.Errorf("Allow() after window got = %+v, want allowed with count 1", decision)
+			}
+		})
+	}
+}
+
+// Test_Limiter_Scripts runs the Lua scripts on miniredis next to the memory limiters, expecting the
+// same decisions from both on every step.
+func Test_Limiter_Scripts(t *testing.T) {
+	current := time.UnixMilli(1700000000000)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	rules := []Rule{{
+		Name:   "Newsletter",
+		Key:    "{a@a.a}:Newsletter",
+		Limit:  2,
+		Burst:  2,
+		Refill: 2,
+		Window: time.Minute,
+	}, {
+		Name:   "Newsletter",
+		Key:    "{a@a.a}:Newsletter:1",
+		Limit:  3,
+		Burst:  3,
+		Refill: 3,
+		Window: time.Hour,
+	}}
+
+	type step struct {
+		op string
+		// elapsed is the time passed before the step
+		elapsed time.Duration
+		allowed bool
+	}
+
+	steps := []step{
+		{op: "Allow", allowed: true},
+		{op: "Allow", allowed: true},
+		{op: "Allow"},
+		{op: "Peek"},
+		{op: "Reserve"},
+		{op: "Release"},
+		{op: "Peek"},
+		{op: "Peek", elapsed: 20 * time.Second},
+		{op: "Allow", elapsed: 50 * time.Second, allowed: true},
+		// the hour rule is exhausted from now on
+		{op: "Reserve"},
+		{op: "Release"},
+		{op: "Allow", elapsed: time.Second},
+		{op: "Allow", elapsed: 2 * time.Minute},
+		{op: "Allow", elapsed: time.Hour, allowed: true},
+	}
+
+	for _, algorithm := range model.Algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			start := current
+			defer func() { current = start }()
+
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			defer rdb.Close()
+
+			limiters := []Limiter{newLimiters(rdb)[algorithm], NewMemoryStore().newLimiters()[algorithm]}
+			last := make([]*Decision, len(limiters))
+			for i, st := range steps {
+				current = current.Add(st.elapsed)
+				mr.FastForward(st.elapsed)
+
+				got := make([]*Decision, len(limiters))
+				for j, l := range limiters {
+					var err error
+					switch st.op {
+					case "Allow":
+						got[j], err = l.Allow(rules)
+					case "Reserve":
+						got[j], err = l.Reserve(rules)
+					case "Peek":
+						got[j], err = l.Peek(rules)
+					case "Release":
+						err = l.Release(rules, last[j])
+					}
+
+					if err != nil {
+						t.Fatalf("step #%d %s() error = %v", i, st.op, err)
+					}
+
+					if got[j] != nil {
+						last[j] = got[j]
+					}
+				}
+
+				if st.op == "Release" {
+					continue
+				}
+
+				script, memory := *got[0], *got[1]
+				script.token, memory.token = "", ""
+				if !reflect.DeepEqual(script, memory) {
+					t.Errorf("step #%d %s() script got = %+v, memory got = %+v", i, st.op, script, memory)
+				}
+
+				if st.op != "Peek" && script.Allowed != st.allowed {
+					t.Errorf("step #%d %s() got allowed = %v, want %v", i, st.op, script.Allowed, st.allowed)
+				}
+			}
+		})
+	}
+}
+
+func Test_memoryStore_deleteCounters(t *testing.T) {
+	current := time.UnixMilli(1700000000000)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    int64
+	}{
+		{
+			name:    "OK_Recipient_Type_Counters_Deleted",
+			pattern: "a@a.a:Newsletter:*",
+			want:    1,
+		}, {
+			name:    "OK_Recipient_Counters_Deleted",
+			pattern: "a@a.a:*",
+			want:    2,
+		}, {
+			name:    "OK_Type_Counters_Deleted",
+			pattern: "*:Newsletter:*",
+			want:    2,
+		}, {
+			name:    "OK_Expired_Counters_Skipped",
+			pattern: "c@c.c:*",
+		}, {
+			name:    "OK_Escaped_Pattern",
+			pattern: `a@a.a:News\*:*`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStore().(*memoryStore)
+			s.set("a@a.a:Newsletter:FIXED_WINDOW", int64(1), time.Minute, current)
+			s.set("a@a.a:Alerts:FIXED_WINDOW", int64(1), time.Minute, current)
+			s.set("b@b.b:Newsletter:FIXED_WINDOW", int64(1), time.Minute, current)
+			s.set("c@c.c:Newsletter:FIXED_WINDOW", int64(1), -time.Second, current)
+
+			got, err := s.deleteCounters(tt.pattern)
+			if err != nil {
+				t.Errorf("deleteCounters() error = %v", err)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("deleteCounters() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_memoryStore_claimDue(t *testing.T) {
+	current := time.UnixMilli(1700000000000)
+
+	s := NewMemoryStore()
+	for member, delay := range map[string]time.Duration{"late": time.Minute, "second": 0, "first": -time.Second, "third": 0} {
+		if err := s.pushDeferred(member, current.Add(delay)); err != nil {
+			t.Fatalf("pushDeferred() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		until time.Time
+		count int64
+		want  []string
+	}{
+		{
+			name:  "OK_Due_Claimed_In_Order",
+			until: current,
+			count: 2,
+			want:  []string{"first", "second"},
+		}, {
+			name:  "OK_Rest_Of_Due_Claimed",
+			until: current,
+			count: 2,
+			want:  []string{"third"},
+		}, {
+			name:  "OK_Nothing_Due",
+			until: current,
+			count: 2,
+			want:  []string{},
+		}, {
+			name:  "OK_Late_Claimed_Once_Due",
+			until: current.Add(time.Minute),
+			count: 2,
+			want:  []string{"late"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.claimDue(tt.until, tt.count)
+			if err != nil {
+				t.Errorf("claimDue() error = %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("claimDue() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_fixedWindowScript runs the fixed window script on miniredis, while the rest of the tests
+// mock its replies.
+func Test_fixedWindowScript(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer rdb.Close()
+
+	config := &model.Config{
+		Name:       "Newsletter",
+		LimitCount: 2,
+		TimeAmount: 1,
+		TimeUnit:   "MINUTE",
+	}
+
+	rules := newRules("a@a.a:Newsletter", config)
+	l := newFixedWindowLimiter(rdb, zap.L())
+	steps := []struct {
+		name string
+		op   operation
+		// elapsed is the time passed before the step
+		elapsed time.Duration
+		want    *Decision
+	}{
+		{
+			name: "OK_Window_Started",
+			op:   allowOp,
+			want: &Decision{
+				Rule:       rules[0],
+				Allowed:    true,
+				Limit:      2,
+				Count:      1,
+				Remaining:  1,
+				ResetAfter: time.Minute,
+			},
+		}, {
+			name:    "OK_Last_Unit_Allowed",
+			op:      allowOp,
+			elapsed: 20 * time.Second,
+			want: &Decision{
+				Rule:       rules[0],
+				Allowed:    true,
+				Limit:      2,
+				Count:      2,
+				RetryAfter: 40 * time.Second,
+				ResetAfter: 40 * time.Second,
+			},
+		}, {
+			name: "OK_Exhausted_Rejected",
+			op:   reserveOp,
+			want: &Decision{
+				Rule:       rules[0],
+				Limit:      2,
+				Count:      3,
+				RetryAfter: 40 * time.Second,
+				ResetAfter: 40 * time.Second,
+			},
+		}, {
+			name:    "OK_Peek_Not_Consumed",
+			op:      peekOp,
+			elapsed: 10 * time.Second,
+			want: &Decision{
+				Rule:       rules[0],
+				Limit:      2,
+				Count:      3,
+				RetryAfter: 30 * time.Second,
+				ResetAfter: 30 * time.Second,
+			},
+		}, {
+			name:    "OK_Window_Reopened",
+			op:      allowOp,
+			elapsed: 30 * time.Second,
+			want: &Decision{
+				Rule:       rules[0],
+				Allowed:    true,
+				Limit:      2,
+				Count:      1,
+				Remaining:  1,
+				ResetAfter: time.Minute,
+			},
+		},
+	}
+	for _, st := range steps {
+		mr.FastForward(st.elapsed)
+
+		var got *Decision
+		var err error
+		switch st.op {
+		case allowOp:
+			got, err = l.Allow(rules)
+		case reserveOp:
+			got, err = l.Reserve(rules)
+		case peekOp:
+			got, err = l.Peek(rules)
+		}
+
+		if err != nil {
+			t.Fatalf("%s: %s() error = %v", st.name, st.op, err)
+		}
+
+		if !reflect.DeepEqual(got, st.want) {
+			t.Errorf("%s: %s() got = %+v, want %+v", st.name, st.op, got, st.want)
+		}
+	}
+}
+
+func Test_LoadScripts(t *testing.T) {
+	SetUp(t)
+
+	tests := []struct {
+		name    string
+		loadCmd redisCmd[string]
+		wantErr bool
+	}{
+		{
+			name:    "OK_Scripts_Loaded",
+			loadCmd: redisCmd[string]{val: "sha"},
+		}, {
+			name:    "ERROR_Redis_Script_Load",
+			loadCmd: redisCmd[string]{err: errors.New("redis: error")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdbMock := Mock[redis.Cmdable]()
+			When(rdbMock.ScriptLoad(AnyString())).
+				ThenReturn(redis.NewStringResult(tt.loadCmd.val, tt.loadCmd.err))
+
+			if err := LoadScripts(rdbMock); (err != nil) != tt.wantErr {
+				t.Errorf("LoadScripts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_partitionRules(t *testing.T) {
+	rules := []Rule{
+		{Key: "{a@a.a}:Newsletter"},
+		{Key: "{@a.a}:Newsletter:1:1h0m0s"},
+		{Key: "{a@a.a}:GROUP:Marketing"},
+		{Key: "{}:Status"},
+		{Key: "{@a.a}:Newsletter:2:24h0m0s"},
+	}
+
+	want := [][]Rule{
+		{rules[0], rules[2]},
+		{rules[1], rules[4]},
+		{rules[3]},
+	}
+
+	if got := partitionRules(rules); !reflect.DeepEqual(got, want) {
+		t.Errorf("partitionRules() got = %v, want %v", got, want)
+	}
+}
+
+func Test_slotLimiter_Allow(t *testing.T) {
+	SetUp(t)
+
+	recipientRule := Rule{Name: "Newsletter", Key: "{a@a.a}:Newsletter", Limit: 2, Window: time.Minute}
+	domainRule := Rule{Name: "a.a", Key: "{@a.a}:Newsletter:1:1h0m0s", Limit: 10, Window: time.Hour}
+	redisErr := errors.New("redis: error")
+
+	decision := func(rule Rule, count int64, allowed bool) *Decision {
+		return &Decision{
+			Allowed:    allowed,
+			Rule:       rule,
+			Limit:      rule.Limit,
+			Count:      count,
+			Remaining:  rule.Limit - count,
+			ResetAfter: rule.Window,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		allowed      map[string]*Decision
+		allowErr     error
+		peeked       map[string]*Decision
+		releaseCalls int
+		peekCalls    int
+		want         *Decision
+		wantErr      bool
+	}{
+		{
+			name: "OK_Allowed",
+			allowed: map[string]*Decision{
+				recipientRule.Key: decision(recipientRule, 1, true),
+				domainRule.Key:    decision(domainRule, 5, true),
+			},
+			want: decision(recipientRule, 1, true),
+		}, {
+			name: "OK_Rejected_By_Recipient",
+			allowed: map[string]*Decision{
+				recipientRule.Key: decision(recipientRule, 2, false),
+			},
+			peeked: map[string]*Decision{
+				domainRule.Key: decision(domainRule, 5, true),
+			},
+			peekCalls: 1,
+			want:      decision(recipientRule, 2, false),
+		}, {
+			name: "OK_Rejected_By_Domain",
+			allowed: map[string]*Decision{
+				recipientRule.Key: decision(recipientRule, 1, true),
+				domainRule.Key:    decision(domainRule, 10, false),
+			},
+			peeked: map[string]*Decision{
+				recipientRule.Key: decision(recipientRule, 0, true),
+			},
+			releaseCalls: 1,
+			peekCalls:    1,
+			want:         decision(domainRule, 10, false),
+		}, {
+			name: "ERROR_Domain_Allow",
+			allowed: map[string]*Decision{
+				recipientRule.Key: decision(recipientRule, 1, true),
+			},
+			allowErr:     redisErr,
+			releaseCalls: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := Mock[Limiter]()
+			WhenDouble(limiter.Allow(Any[[]Rule]())).ThenAnswer(func(args []any) (*Decision, error) {
+				if decision, exists := tt.allowed[args[0].([]Rule)[0].Key]; exists {
+					return decision, nil
+				}
+				return nil, tt.allowErr
+			})
+			WhenDouble(limiter.Peek(Any[[]Rule]())).ThenAnswer(func(args []any) (*Decision, error) {
+				return tt.peeked[args[0].([]Rule)[0].Key], nil
+			})
+			When(limiter.Release(Any[[]Rule](), Any[*Decision]())).ThenReturn(nil)
+
+			l := newSlotLimiters(map[string]Limiter{model.FixedWindow: limiter})[model.FixedWindow]
+			got, err := l.Allow([]Rule{recipientRule, domainRule})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Allow() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			Verify(limiter, Times(tt.releaseCalls)).Release(Any[[]Rule](), Any[*Decision]())
+			Verify(limiter, Times(tt.peekCalls)).Peek(Any[[]Rule]())
+			if tt.wantErr {
+				return
+			}
+
+			if len(got.parts) != 2 {
+				t.Errorf("Allow() got %d parts, want 2", len(got.parts))
+				return
+			}
+
+			got.parts = nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Allow() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_circuitBreaker_Send(t *testing.T) {
+	SetUp(t)
+
+	current := time.UnixMilli(1700000000000)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	notification := &pb.Notification{
+		Recipient:        "a@a.a",
+		Message:          "Hello world",
+		NotificationType: "Newsletter",
+	}
+
+	sent := &pb.Result{
+		Status:          pb.Status_SENT,
+		ResponseMessage: "notification sent to recipient",
+	}
+
+	sendErr := errors.New("rpc error: code = DeadlineExceeded")
+
+	type step struct {
+		elapse    time.Duration
+		sendErr   error
+		wantErr   error
+		wantState string
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "OK_Opens_After_Consecutive_Failures",
+			steps: []step{
+				{sendErr: sendErr, wantErr: sendErr, wantState: model.BreakerClosed},
+				{wantState: model.BreakerClosed},
+				{sendErr: sendErr, wantErr: sendErr, wantState: model.BreakerClosed},
+				{sendErr: sendErr, wantErr: sendErr, wantState: model.BreakerOpen},
+				{elapse: 59 * time.Second, wantErr: ErrCircuitOpen, wantState: model.BreakerOpen},
+			},
+		}, {
+			name: "OK_Closes_After_Half_Open_Successes",
+			steps: []step{
+				{sendErr: sendErr, wantErr: sendErr, wantState: model.BreakerClosed},
+				{sendErr: sendErr, wantErr: sendErr, wantState: model.BreakerOpen},
+				{elapse: time.Minute, wantState: model.BreakerHalfOpen},
+				{wantState: model.BreakerClosed},
+			},
+		}, {
+			name: "OK_Reopens_On_Half_Open_Failure",
+			steps: []step{
+				{sendErr: sendErr, wantErr: sendErr, wantState: model.BreakerClosed},
+				{sendErr: sendErr, wantErr: sendErr, wantState: model.BreakerOpen},
+				{elapse: time.Minute, sendErr: sendErr, wantErr: sendErr, wantState: model.BreakerOpen},
+				{elapse: time.Second, wantErr: ErrCircuitOpen, wantState: model.BreakerOpen},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current = time.UnixMilli(1700000000000)
+			var next error
+			dlgMock := Mock[service.Client]()
+			WhenDouble(dlgMock.Send(Any[*pb.Notification]())).ThenAnswer(func(args []any) (*pb.Result, error) {
+				if next != nil {
+					return InternalErrorResult, next
+				}
+				return sent, nil
+			})
+
+			b := NewCircuitBreaker(dlgMock, 2, time.Minute, 2)
+			for i, s := range tt.steps {
+				current = current.Add(s.elapse)
+				next = s.sendErr
+
+				got, err := b.Send(notification)
+				if !errors.Is(err, s.wantErr) || (s.wantErr == nil && err != nil) {
+					t.Errorf("Send() #%d error = %v, wantErr %v", i, err, s.wantErr)
+					return
+				}
+
+				if errors.Is(err, ErrCircuitOpen) && !proto.Equal(got, CircuitOpenResult) {
+					t.Errorf("Send() #%d got = %v, want %v", i, got, CircuitOpenResult)
+					return
+				}
+
+				if state := b.Status().State; state != s.wantState {
+					t.Errorf("Status() #%d got = %v, want %v", i, state, s.wantState)
+					return
+				}
+			}
+		})
+	}
+}
+
+func Test_client_Status(t *testing.T) {
+	SetUp(t)
+
+	current := time.UnixMilli(1700000000000)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	dlgMock := Mock[service.Client]()
+	When(dlgMock.Send(Any[*pb.Notification]())).ThenReturn(InternalErrorResult, errors.New("rpc error"))
+
+	breaker := NewCircuitBreaker(dlgMock, 1, time.Minute, 1)
+	if _, err := breaker.Send(&pb.Notification{}); err == nil {
+		t.Errorf("Send() error = nil, want error")
+		return
+	}
+
+	c := &client{
+		delegate: breaker,
+		health:   newHealthDetector(NewMemoryStore()),
+		logger:   zap.L(),
+	}
+
+	openedAt := current.UTC()
+	want := &model.Status{
+		Store: model.StatusUp,
+		Delegate: &model.BreakerStatus{
+			State:               model.BreakerOpen,
+			ConsecutiveFailures: 1,
+			OpenedAt:            &openedAt,
+		},
+	}
+
+	if got := c.Status(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Status() got = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package ratelimiter
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/sebasir/rate-limiter-example/app_errors"
+	"github.com/sebasir/rate-limiter-example/model"
+	"go.uber.org/zap"
+	"slices"
+	"strings"
+)
+
+var ErrResettingCounters = errors.New("error resetting rate limit counters")
+
+// reservedPrefixes are the keys sharing the Redis database with the counters, which a type-wide
+// pattern could otherwise match.
+var reservedPrefixes = []string{
+	model.NotificationConfigSet + ":",
+	model.RecipientOverrideSet + ":",
+	model.ShadowRejectionSet,
+	model.DeferredNotificationSet,
+	model.DeduplicationPrefix + ":",
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// ResetCounters deletes the counters of the recipient for the notification type. When either of
+// them is empty, it deletes the counters of every type for the recipient, or the counters of every
+// recipient (including the domain ones) for the type.
+func (c *client) ResetCounters(recipient, notificationType, actor string) (int64, error) {
+	recipientField := zap.String("recipient", recipient)
+	typeField := zap.String("notification_type", notificationType)
+	if recipient == "" && notificationType == "" {
+		return 0, LogAndError("error trying to reset counters without recipient nor notification type",
+			ErrResettingCounters, c.logger)
+	}
+
+	var deleted int64
+	for _, pattern := range counterPatterns(globEscaper.Replace(recipient), globEscaper.Replace(notificationType)) {
+		count, err := c.store.deleteCounters(pattern)
+		if err != nil {
+			return deleted, LogAndError("error trying to reset counters",
+				errors.Join(err, ErrResettingCounters), c.logger, recipientField, typeField, zap.String("pattern", pattern))
+		}
+		deleted += count
+	}
+
+	c.logger.Named("audit").Info("rate limit counters reset", zap.String("actor", actor),
+		recipientField, typeField, zap.Int64("deleted", deleted))
+	return deleted, nil
+}
+
+func counterPatterns(recipient, notificationType string) []string {
+	switch {
+	case notificationType == "":
+		return []string{fmt.Sprintf("{%s}:*", recipient)}
+	case recipient == "":
+		return []string{fmt.Sprintf("*}:%s", notificationType), fmt.Sprintf("*}:%s:*", notificationType)}
+	default:
+		return []string{counterKey(recipient, notificationType), counterKey(recipient, notificationType+":*")}
+	}
+}
+
+func isReserved(key string) bool {
+	return slices.ContainsFunc(reservedPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
@@ -0,0 +1,54 @@
+package ratelimiter
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/go-redis/redis"
+	"github.com/sebasir/rate-limiter-example/redis_cluster"
+	"strings"
+)
+
+var scripts []*script
+
+// script mirrors redis.Script, but runs against redis.Cmdable so any implementation of it
+// (including mocks) can evaluate the Lua sources in this package.
+type script struct {
+	src  string
+	hash string
+}
+
+func newScript(src string) *script {
+	sum := sha1.Sum([]byte(src))
+	s := &script{
+		src:  src,
+		hash: hex.EncodeToString(sum[:]),
+	}
+
+	scripts = append(scripts, s)
+	return s
+}
+
+// run uses EVALSHA, falling back to EVAL when the script is not cached (e.g. after a restart of
+// the Redis server).
+func (s *script) run(rdb redis.Cmdable, keys []string, args ...interface{}) *redis.Cmd {
+	cmd := rdb.EvalSha(s.hash, keys, args...)
+	if err := cmd.Err(); err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT ") {
+		return rdb.Eval(s.src, keys, args...)
+	}
+
+	return cmd
+}
+
+// LoadScripts caches every limiter script in the Redis server (or every master of a Redis
+// Cluster), so they are evaluated with EVALSHA from the first notification on.
+func LoadScripts(rdb redis.Cmdable) error {
+	return redis_cluster.ForEachMaster(rdb, func(node redis.Cmdable) error {
+		for _, s := range scripts {
+			if err := node.ScriptLoad(s.src).Err(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,128 @@
+package ratelimiter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis"
+	. "github.com/sebasir/rate-limiter-example/app_errors"
+	"github.com/sebasir/rate-limiter-example/model"
+	"go.uber.org/zap"
+	"math/rand"
+)
+
+// slidingWindowLogScript keeps a sorted set of send timestamps per rule, and counts the entries
+// within each trailing window. Unless peeking, it trims the entries that fell out of the windows
+// and logs the current attempt when it fits every rule (or always, when reserving).
+var slidingWindowLogScript = newScript(`
+local op = ARGV[1]
+local now = tonumber(ARGV[2])
+
+local counts = {}
+local allowed = true
+for i, key in ipairs(KEYS) do
+	counts[i] = redis.call('ZCOUNT', key, '(' .. (now - tonumber(ARGV[i * 2 + 2])), '+inf')
+	if counts[i] >= tonumber(ARGV[i * 2 + 3]) then
+		allowed = false
+	end
+end
+
+local decisive, fewest, retry, reset = 1, math.huge, 0, 0
+for i, key in ipairs(KEYS) do
+	local window = tonumber(ARGV[i * 2 + 2])
+	local limit = tonumber(ARGV[i * 2 + 3])
+	local start = '(' .. (now - window)
+	if op ~= 'peek' then
+		redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+		if allowed or op == 'reserve' then
+			redis.call('ZADD', key, now, ARGV[3])
+			redis.call('PEXPIRE', key, window)
+			counts[i] = counts[i] + 1
+		end
+	end
+
+	if counts[i] >= limit then
+		local entry = redis.call('ZRANGEBYSCORE', key, start, '+inf', 'WITHSCORES', 'LIMIT', counts[i] - limit, 1)
+		retry = math.max(retry, tonumber(entry[2]) + window - now)
+	end
+
+	if limit - counts[i] < fewest then
+		decisive, fewest, reset = i, limit - counts[i], 0
+		local newest = redis.call('ZREVRANGEBYSCORE', key, '+inf', start, 'WITHSCORES', 'LIMIT', 0, 1)
+		if #newest == 2 then
+			reset = tonumber(newest[2]) + window - now
+		end
+	end
+end
+
+return {allowed and 1 or 0, decisive, counts[decisive], retry, reset}
+`)
+
+// slidingWindowLogReleaseScript removes the logged attempt from every rule.
+var slidingWindowLogReleaseScript = newScript(`
+for _, key in ipairs(KEYS) do
+	redis.call('ZREM', key, ARGV[1])
+end
+
+return #KEYS
+`)
+
+func init() {
+	registerLimiter(model.SlidingWindowLog, newSlidingWindowLogLimiter)
+}
+
+type slidingWindowLogLimiter struct {
+	rdb    redis.Cmdable
+	logger *zap.Logger
+}
+
+func newSlidingWindowLogLimiter(rdb redis.Cmdable, logger *zap.Logger) Limiter {
+	return &slidingWindowLogLimiter{
+		rdb:    rdb,
+		logger: logger,
+	}
+}
+
+func (l *slidingWindowLogLimiter) Allow(rules []Rule) (*Decision, error) {
+	return l.evaluate(allowOp, rules)
+}
+
+func (l *slidingWindowLogLimiter) Reserve(rules []Rule) (*Decision, error) {
+	return l.evaluate(reserveOp, rules)
+}
+
+func (l *slidingWindowLogLimiter) Peek(rules []Rule) (*Decision, error) {
+	return l.evaluate(peekOp, rules)
+}
+
+func (l *slidingWindowLogLimiter) evaluate(op operation, rules []Rule) (*Decision, error) {
+	keys := ruleKeys(rules, model.SlidingWindowLog)
+
+	current := now()
+	member := fmt.Sprintf("%d-%d", current.UnixNano(), rand.Int63())
+	args := []interface{}{string(op), current.UnixMilli(), member}
+	for _, rule := range rules {
+		args = append(args, rule.Window.Milliseconds(), rule.Limit)
+	}
+
+	decision, err := decisionFromReply(slidingWindowLogScript.run(l.rdb, keys, args...), rules, fixedWindowLimit)
+	if err != nil {
+		return nil, LogAndError("error trying to evaluate sliding window log",
+			errors.Join(err, ErrProcessingNotificationRequest), l.logger, zap.Strings("keys", keys))
+	}
+
+	if op != peekOp {
+		decision.token = member
+	}
+
+	return decision, nil
+}
+
+func (l *slidingWindowLogLimiter) Release(rules []Rule, decision *Decision) error {
+	keys := ruleKeys(rules, model.SlidingWindowLog)
+	if err := slidingWindowLogReleaseScript.run(l.rdb, keys, decision.token).Err(); err != nil {
+		return LogAndError("error trying to release sliding window log",
+			errors.Join(err, ErrProcessingNotificationRequest), l.logger, zap.Strings("keys", keys))
+	}
+
+	return nil
+}
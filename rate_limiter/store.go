@@ -0,0 +1,151 @@
+package ratelimiter
+
+import (
+	"github.com/go-redis/redis"
+	"github.com/sebasir/rate-limiter-example/model"
+	"github.com/sebasir/rate-limiter-example/redis_cluster"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Store keeps the state of the limits: the counters evaluated by the limiters, and the delay queue
+// of the deferred notifications.
+type Store interface {
+	// newLimiters builds a Limiter for every algorithm, keeping its counters in the store.
+	newLimiters() map[string]Limiter
+	// deleteCounters deletes the counters matching the glob pattern, returning how many were deleted.
+	deleteCounters(pattern string) (int64, error)
+	// pushDeferred queues the serialized deferred notification to be delivered at scheduledAt.
+	pushDeferred(member string, scheduledAt time.Time) error
+	// claimDue takes up to count notifications due by until out of the queue, returning the ones
+	// claimed so far even on error.
+	claimDue(until time.Time, count int64) ([]string, error)
+	// remember stores the key for ttl unless already stored, reporting whether it was stored.
+	remember(key string, ttl time.Duration) (bool, error)
+	// forget deletes the key stored by remember.
+	forget(key string) error
+	// ping checks the store can be reached.
+	ping() error
+}
+
+type redisStore struct {
+	rdb     redis.Cmdable
+	cluster bool
+}
+
+// NewRedisStore builds a Store on a Redis server, shared by every instance connected to it. On
+// Redis Cluster, the rules of a recipient are evaluated apart from the ones of its email domain,
+// living on different slots.
+func NewRedisStore(rdb redis.Cmdable) Store {
+	_, cluster := rdb.(*redis.ClusterClient)
+	return &redisStore{
+		rdb:     rdb,
+		cluster: cluster,
+	}
+}
+
+func (s *redisStore) newLimiters() map[string]Limiter {
+	if s.cluster {
+		return newSlotLimiters(newLimiters(s.rdb))
+	}
+
+	return newLimiters(s.rdb)
+}
+
+func (s *redisStore) deleteCounters(pattern string) (int64, error) {
+	var deleted atomic.Int64
+	err := redis_cluster.ForEachMaster(s.rdb, func(node redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := node.Scan(cursor, pattern, 100).Result()
+			if err != nil {
+				return err
+			}
+
+			counters := make([]string, 0, len(keys))
+			for _, key := range keys {
+				if !isReserved(key) {
+					counters = append(counters, key)
+				}
+			}
+
+			if len(counters) > 0 {
+				count, err := s.delete(counters)
+				deleted.Add(count)
+				if err != nil {
+					return err
+				}
+			}
+
+			if cursor = next; cursor == 0 {
+				return nil
+			}
+		}
+	})
+
+	return deleted.Load(), err
+}
+
+// delete deletes the keys one by one on Redis Cluster, where DEL only takes keys of the same slot.
+func (s *redisStore) delete(keys []string) (int64, error) {
+	if !s.cluster {
+		return s.rdb.Del(keys...).Result()
+	}
+
+	var deleted int64
+	for _, key := range keys {
+		count, err := s.rdb.Del(key).Result()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += count
+	}
+
+	return deleted, nil
+}
+
+func (s *redisStore) pushDeferred(member string, scheduledAt time.Time) error {
+	return s.rdb.ZAdd(model.DeferredNotificationSet, redis.Z{
+		Score:  float64(scheduledAt.UnixMilli()),
+		Member: member,
+	}).Err()
+}
+
+func (s *redisStore) claimDue(until time.Time, count int64) ([]string, error) {
+	members, err := s.rdb.ZRangeByScore(model.DeferredNotificationSet, redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(until.UnixMilli(), 10),
+		Count: count,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]string, 0, len(members))
+	for _, member := range members {
+		// removing the member claims it, so a single instance delivers it
+		removed, err := s.rdb.ZRem(model.DeferredNotificationSet, member).Result()
+		if err != nil {
+			return claimed, err
+		}
+
+		if removed == 1 {
+			claimed = append(claimed, member)
+		}
+	}
+
+	return claimed, nil
+}
+
+func (s *redisStore) remember(key string, ttl time.Duration) (bool, error) {
+	return s.rdb.SetNX(key, 1, ttl).Result()
+}
+
+func (s *redisStore) forget(key string) error {
+	return s.rdb.Del(key).Err()
+}
+
+func (s *redisStore) ping() error {
+	return s.rdb.Ping().Err()
+}
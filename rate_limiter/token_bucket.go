@@ -0,0 +1,126 @@
+package ratelimiter
+
+import (
+	"errors"
+	"github.com/go-redis/redis"
+	. "github.com/sebasir/rate-limiter-example/app_errors"
+	"github.com/sebasir/rate-limiter-example/model"
+	"go.uber.org/zap"
+)
+
+// tokenBucketScript refills every rule bucket according to the time elapsed since its last update,
+// and unless peeking, takes a token from each of them when all have one available (or always,
+// leaving buckets in debt, when reserving).
+var tokenBucketScript = newScript(`
+local op = ARGV[1]
+local now = tonumber(ARGV[2])
+
+local tokens = {}
+local allowed = true
+for i, key in ipairs(KEYS) do
+	local capacity = tonumber(ARGV[i * 3])
+	local rate = tonumber(ARGV[i * 3 + 1]) / tonumber(ARGV[i * 3 + 2])
+	local state = redis.call('HMGET', key, 'tokens', 'ts')
+	local last = tonumber(state[2]) or now
+	tokens[i] = math.min(capacity, (tonumber(state[1]) or capacity) + math.max(0, now - last) * rate)
+	if tokens[i] < 1 then
+		allowed = false
+	end
+end
+
+local decisive, fewest, retry, reset = 1, math.huge, 0, 0
+for i, key in ipairs(KEYS) do
+	local capacity = tonumber(ARGV[i * 3])
+	local rate = tonumber(ARGV[i * 3 + 1]) / tonumber(ARGV[i * 3 + 2])
+	if op ~= 'peek' and (allowed or op == 'reserve') then
+		tokens[i] = tokens[i] - 1
+		redis.call('HMSET', key, 'tokens', tokens[i], 'ts', now)
+		redis.call('PEXPIRE', key, math.max(1, math.ceil((capacity - tokens[i]) / rate)))
+	end
+
+	if tokens[i] < 1 then
+		retry = math.max(retry, math.ceil((1 - tokens[i]) / rate))
+	end
+
+	if math.floor(tokens[i]) < fewest then
+		decisive, fewest, reset = i, math.floor(tokens[i]), math.ceil((capacity - tokens[i]) / rate)
+	end
+end
+
+return {allowed and 1 or 0, decisive, tonumber(ARGV[decisive * 3]) - fewest, retry, reset}
+`)
+
+// tokenBucketReleaseScript puts a token back into every existing bucket, up to its capacity.
+var tokenBucketReleaseScript = newScript(`
+for i, key in ipairs(KEYS) do
+	local tokens = tonumber(redis.call('HGET', key, 'tokens'))
+	if tokens then
+		redis.call('HSET', key, 'tokens', math.min(tokens + 1, tonumber(ARGV[i])))
+	end
+end
+
+return #KEYS
+`)
+
+func init() {
+	registerLimiter(model.TokenBucket, newTokenBucketLimiter)
+}
+
+type tokenBucketLimiter struct {
+	rdb    redis.Cmdable
+	logger *zap.Logger
+}
+
+func newTokenBucketLimiter(rdb redis.Cmdable, logger *zap.Logger) Limiter {
+	return &tokenBucketLimiter{
+		rdb:    rdb,
+		logger: logger,
+	}
+}
+
+func (l *tokenBucketLimiter) Allow(rules []Rule) (*Decision, error) {
+	return l.evaluate(allowOp, rules)
+}
+
+func (l *tokenBucketLimiter) Reserve(rules []Rule) (*Decision, error) {
+	return l.evaluate(reserveOp, rules)
+}
+
+func (l *tokenBucketLimiter) Peek(rules []Rule) (*Decision, error) {
+	return l.evaluate(peekOp, rules)
+}
+
+func (l *tokenBucketLimiter) evaluate(op operation, rules []Rule) (*Decision, error) {
+	keys := ruleKeys(rules, model.TokenBucket)
+	args := []interface{}{string(op), now().UnixMilli()}
+	for _, rule := range rules {
+		args = append(args, rule.Burst, rule.Refill, rule.Window.Milliseconds())
+	}
+
+	decision, err := decisionFromReply(tokenBucketScript.run(l.rdb, keys, args...), rules, tokenBucketLimit)
+	if err != nil {
+		return nil, LogAndError("error trying to evaluate token bucket",
+			errors.Join(err, ErrProcessingNotificationRequest), l.logger, zap.Strings("keys", keys))
+	}
+
+	return decision, nil
+}
+
+func (l *tokenBucketLimiter) Release(rules []Rule, _ *Decision) error {
+	keys := ruleKeys(rules, model.TokenBucket)
+	args := make([]interface{}, len(rules))
+	for i, rule := range rules {
+		args[i] = rule.Burst
+	}
+
+	if err := tokenBucketReleaseScript.run(l.rdb, keys, args...).Err(); err != nil {
+		return LogAndError("error trying to release token bucket",
+			errors.Join(err, ErrProcessingNotificationRequest), l.logger, zap.Strings("keys", keys))
+	}
+
+	return nil
+}
+
+func tokenBucketLimit(rule Rule) int64 {
+	return rule.Burst
+}
@@ -0,0 +1,18 @@
+package redis_cluster
+
+import (
+	"github.com/go-redis/redis"
+)
+
+// ForEachMaster runs fn on every master of the cluster, or on rdb itself when it is not a cluster
+// client. Redis Cluster runs fn concurrently.
+func ForEachMaster(rdb redis.Cmdable, fn func(node redis.Cmdable) error) error {
+	cluster, ok := rdb.(*redis.ClusterClient)
+	if !ok {
+		return fn(rdb)
+	}
+
+	return cluster.ForEachMaster(func(node *redis.Client) error {
+		return fn(node)
+	})
+}
@@ -12,9 +12,33 @@ type Client interface {
 type ConfigClient interface {
 	ListNotificationConfig() ([]*model.Config, error)
 	PersistNotificationConfig(*model.Config) error
+	ListShadowRejections() (map[string]int64, error)
+}
+
+type OverrideClient interface {
+	ListRecipientOverrides(recipient string) ([]*model.Override, error)
+	PersistRecipientOverride(*model.Override) error
+	DeleteRecipientOverride(recipient, notificationType string) error
+}
+
+type QuotaClient interface {
+	GetQuota(recipient, notificationType string) (*model.Quota, error)
+	ListQuotas(recipient string) ([]*model.Quota, error)
+}
+
+type ResetClient interface {
+	ResetCounters(recipient, notificationType, actor string) (int64, error)
+}
+
+type StatusClient interface {
+	Status() *model.Status
 }
 
 type ExtendedClient interface {
 	Client
 	ConfigClient
+	OverrideClient
+	QuotaClient
+	ResetClient
+	StatusClient
 }